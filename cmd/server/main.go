@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"embed"
 	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
 	"log/slog"
@@ -15,6 +17,7 @@ import (
 
 	"hackernews/internal/cache"
 	"hackernews/internal/config"
+	"hackernews/internal/gemini"
 	"hackernews/internal/handler"
 	"hackernews/internal/hn"
 	"hackernews/internal/view"
@@ -33,7 +36,17 @@ func main() {
 }
 
 func run(logger *slog.Logger) error {
-	cfg := config.New()
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.PrintConfig {
+		return cfg.WriteJSON(os.Stdout)
+	}
 
 	templateSubFS, err := fs.Sub(webFS, "web/template")
 	if err != nil {
@@ -50,15 +63,43 @@ func run(logger *slog.Logger) error {
 		return fmt.Errorf("failed to create sub-filesystem for static assets: %w", err)
 	}
 
-	hnClient := hn.NewClient(logger, cfg)
+	backend := hn.NewHTTPBackend(&cfg.HackerNewsAPI)
+	hnClient := hn.NewClient(logger, cfg, backend)
+
+	var persister *cache.Persister
+	if cfg.Cache.SnapshotDir != "" {
+		persister = cache.NewPersister(cfg.Cache.SnapshotDir, cfg.Cache.SnapshotInterval, logger)
+		for name, c := range hnClient.Caches() {
+			persister.Register(name, c)
+		}
+		persister.Restore()
+		go persister.Start()
+	}
 
-	refresher := cache.NewRefresher(hnClient, logger, 90*time.Second)
+	refresher := cache.NewRefresher(hnClient, logger, cfg.Refresher,
+		cache.WithUpdates(hnClient, cfg.Refresher.UpdatesInterval),
+		cache.WithPrewarm(hnClient, cfg.Refresher.Prewarm),
+	)
 	go refresher.Start()
 
+	streamCtx, stopStreams := context.WithCancel(context.Background())
+	defer stopStreams()
+	if cfg.Stream.Enabled {
+		stream := hn.NewStream(&cfg.HackerNewsAPI, logger)
+		for _, storyType := range cfg.Stream.Feeds {
+			go hnClient.WatchStoryIDs(streamCtx, stream, storyType, hn.WithItemStreams(cfg.Stream.Items))
+		}
+		if cfg.Stream.MaxItem {
+			go hnClient.WatchMaxItem(streamCtx, stream)
+		}
+	}
+
 	app := &handler.App{
 		Logger:        logger,
 		Config:        cfg,
 		HackerNews:    hnClient,
+		Caches:        hnClient.Caches(),
+		Refresher:     refresher,
 		TemplateCache: templateCache,
 		StaticFS:      staticSubFS,
 	}
@@ -66,12 +107,94 @@ func run(logger *slog.Logger) error {
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      app.Routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
 		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 	}
 
+	var adminSrv *http.Server
+	if cfg.Admin.Addr != "" {
+		adminSrv = &http.Server{
+			Addr:         cfg.Admin.Addr,
+			Handler:      app.AdminRoutes(),
+			IdleTimeout:  cfg.Server.IdleTimeout,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		}
+
+		go func() {
+			logger.Info("starting admin server", "addr", adminSrv.Addr)
+			if err := adminSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("admin server error", "error", err)
+			}
+		}()
+	}
+
+	var geminiSrv *gemini.Server
+	if cfg.Gemini.Addr != "" {
+		cert, created, err := gemini.LoadOrCreateCertificate(cfg.Gemini.CertFile, cfg.Gemini.KeyFile, cfg.Gemini.Hostname)
+		if err != nil {
+			return err
+		}
+		if created {
+			logger.Info("generated self-signed gemini certificate", "cert", cfg.Gemini.CertFile, "hostname", cfg.Gemini.Hostname)
+		}
+
+		geminiSrv = &gemini.Server{
+			Addr:     cfg.Gemini.Addr,
+			Handler:  app.GeminiRoutes(),
+			Hostname: cfg.Gemini.Hostname,
+			TLSConfig: &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			},
+			ReadTimeout:  cfg.Gemini.ReadTimeout,
+			WriteTimeout: cfg.Gemini.WriteTimeout,
+			Logger:       logger,
+		}
+
+		go func() {
+			logger.Info("starting gemini server", "addr", geminiSrv.Addr)
+			if err := geminiSrv.ListenAndServe(); !errors.Is(err, gemini.ErrServerClosed) {
+				logger.Error("gemini server error", "error", err)
+			}
+		}()
+	}
+
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+
+		current := cfg
+		for range hup {
+			next, _, err := config.Load(os.Args[1:], os.LookupEnv)
+			if err != nil {
+				logger.Error("failed to reload configuration", "error", err)
+				continue
+			}
+
+			var applied, ignored []string
+			for _, name := range config.Diff(current, next) {
+				if config.Reloadable(name) {
+					applied = append(applied, name)
+				} else {
+					ignored = append(ignored, name)
+				}
+			}
+			if len(ignored) > 0 {
+				logger.Warn("changed settings require a restart", "settings", ignored)
+			}
+
+			current = current.Merge(next)
+			backend.Reload(&current.HackerNewsAPI)
+			hnClient.Reload(current)
+			app.Reload(current)
+			logger.Info("reloaded configuration", "settings", applied)
+		}
+	}()
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -81,11 +204,28 @@ func run(logger *slog.Logger) error {
 
 		logger.Info("shutting down server", "signal", s.String())
 		refresher.Stop()
+		stopStreams()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		shutdownError <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+		if adminSrv != nil {
+			if adminErr := adminSrv.Shutdown(ctx); adminErr != nil {
+				logger.Error("error shutting down admin server", "error", adminErr)
+			}
+		}
+		if geminiSrv != nil {
+			if geminiErr := geminiSrv.Shutdown(ctx); geminiErr != nil {
+				logger.Error("error shutting down gemini server", "error", geminiErr)
+			}
+		}
+		if persister != nil {
+			persister.Stop()
+		}
+		hnClient.Close()
+
+		shutdownError <- err
 	}()
 
 	logger.Info("starting server", "addr", srv.Addr)
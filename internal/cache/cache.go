@@ -1,6 +1,11 @@
 package cache
 
 import (
+	"container/list"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"time"
 )
@@ -10,37 +15,476 @@ type CacheItem[T any] struct {
 	Expiration int64
 }
 
+type Freshness int
+
+const (
+	Miss Freshness = iota
+	Fresh
+	Stale
+	Expired
+)
+
+func (f Freshness) String() string {
+	switch f {
+	case Fresh:
+		return "fresh"
+	case Stale:
+		return "stale"
+	case Expired:
+		return "expired"
+	default:
+		return "miss"
+	}
+}
+
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	StaleHits   uint64 `json:"stale_hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+	Entries     int    `json:"entries"`
+	Bytes       int    `json:"bytes"`
+
+	// OldestEntry and NewestEntry are when the least and most recently set
+	// entries still retained were stored. Both are zero for an empty cache.
+	OldestEntry time.Time `json:"oldest_entry"`
+	NewestEntry time.Time `json:"newest_entry"`
+}
+
+type Managed interface {
+	Snapshotter
+	Stats() Stats
+	Delete(key string) bool
+	DeletePrefix(prefix string) int
+	Purge()
+}
+
+type Sizer interface {
+	Size() int
+}
+
+type entry[T any] struct {
+	key  string
+	item CacheItem[T]
+	size int
+}
+
 type Cache[T any] struct {
-	items    map[string]CacheItem[T]
-	mu       sync.RWMutex
+	items    map[string]*list.Element
+	order    *list.List
+	mu       sync.Mutex
 	duration time.Duration
+	bytes    int
+	stats    Stats
+	opts     options
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
 }
 
-func New[T any](duration time.Duration) *Cache[T] {
-	return &Cache[T]{
-		items:    make(map[string]CacheItem[T]),
+type options struct {
+	maxEntries           int
+	maxBytes             int
+	janitorInterval      time.Duration
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+}
+
+type Option func(*options)
+
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the approximate memory held by the cache. Only values
+// implementing Sizer count towards the limit.
+func WithMaxBytes(n int) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+func WithJanitor(interval time.Duration) Option {
+	return func(o *options) {
+		o.janitorInterval = interval
+	}
+}
+
+// WithStaleWhileRevalidate keeps entries for d past their expiration and
+// reports them as Stale, so callers can serve them while refreshing.
+func WithStaleWhileRevalidate(d time.Duration) Option {
+	return func(o *options) {
+		o.staleWhileRevalidate = d
+	}
+}
+
+// WithStaleIfError keeps entries for d past their expiration and reports them
+// as Expired, so callers can fall back to them when a refresh fails.
+func WithStaleIfError(d time.Duration) Option {
+	return func(o *options) {
+		o.staleIfError = d
+	}
+}
+
+func New[T any](duration time.Duration, opts ...Option) *Cache[T] {
+	c := &Cache[T]{
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
 		duration: duration,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	if c.opts.janitorInterval > 0 {
+		go c.janitor()
+	} else {
+		close(c.done)
+	}
+
+	return c
 }
 
 func (c *Cache[T]) Set(key string, value T) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.items[key] = CacheItem[T]{
-		Value:      value,
-		Expiration: time.Now().Add(c.duration).UnixNano(),
+
+	size := 0
+	if sizer, ok := any(value).(Sizer); ok {
+		size = sizer.Size()
+	}
+	// A value that can never fit is not stored, rather than evicting
+	// everything else first. Any older value for the key goes too.
+	if c.opts.maxBytes > 0 && size > c.opts.maxBytes {
+		if elem, found := c.items[key]; found {
+			c.remove(elem)
+		}
+		return
 	}
+
+	e := &entry[T]{
+		key: key,
+		item: CacheItem[T]{
+			Value:      value,
+			Expiration: time.Now().Add(c.duration).UnixNano(),
+		},
+		size: size,
+	}
+
+	if elem, found := c.items[key]; found {
+		c.bytes -= elem.Value.(*entry[T]).size
+		elem.Value = e
+		c.order.MoveToFront(elem)
+	} else {
+		c.items[key] = c.order.PushFront(e)
+	}
+	c.bytes += size
+
+	c.evict()
 }
 
 func (c *Cache[T]) Get(key string) (T, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.items[key]
+	if !found {
+		c.stats.Misses++
+		var zero T
+		return zero, false
+	}
 
-	item, found := c.items[key]
-	if !found || time.Now().UnixNano() > item.Expiration {
+	e := elem.Value.(*entry[T])
+	if time.Now().UnixNano() > e.item.Expiration {
+		c.stats.Misses++
 		var zero T
 		return zero, false
 	}
 
-	return item.Value, true
+	c.stats.Hits++
+	c.order.MoveToFront(elem)
+	return e.item.Value, true
+}
+
+func (c *Cache[T]) GetStale(key string) (T, Freshness) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.items[key]
+	if !found {
+		c.stats.Misses++
+		var zero T
+		return zero, Miss
+	}
+
+	e := elem.Value.(*entry[T])
+	now := time.Now().UnixNano()
+	var state Freshness
+	switch {
+	case now <= e.item.Expiration:
+		state = Fresh
+		c.stats.Hits++
+	case now <= e.item.Expiration+int64(c.opts.staleWhileRevalidate):
+		state = Stale
+		c.stats.StaleHits++
+	case now <= e.item.Expiration+int64(c.retention()):
+		state = Expired
+		c.stats.Misses++
+	default:
+		c.stats.Misses++
+		var zero T
+		return zero, Miss
+	}
+
+	c.order.MoveToFront(elem)
+	return e.item.Value, state
+}
+
+// Has reports whether key is retained, without touching recency or stats.
+func (c *Cache[T]) Has(key string) bool {
+	_, found := c.Peek(key)
+	return found
+}
+
+// Peek returns a retained value regardless of its freshness, without touching
+// recency or stats.
+func (c *Cache[T]) Peek(key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.items[key]
+	if !found {
+		var zero T
+		return zero, false
+	}
+	return elem.Value.(*entry[T]).item.Value, true
+}
+
+// Range calls fn for every retained value until fn returns false, without
+// touching recency or stats.
+func (c *Cache[T]) Range(fn func(key string, value T) bool) {
+	c.mu.Lock()
+	entries := make([]*entry[T], 0, len(c.items))
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, elem.Value.(*entry[T]))
+	}
+	c.mu.Unlock()
+
+	for _, e := range entries {
+		if !fn(e.key, e.item.Value) {
+			return
+		}
+	}
+}
+
+func (c *Cache[T]) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.items[key]
+	if found {
+		c.remove(elem)
+	}
+	return found
+}
+
+func (c *Cache[T]) DeletePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, elem := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (c *Cache[T]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.items)
+	stats.Bytes = c.bytes
+
+	var oldest, newest int64
+	for _, elem := range c.items {
+		exp := elem.Value.(*entry[T]).item.Expiration
+		if oldest == 0 || exp < oldest {
+			oldest = exp
+		}
+		newest = max(newest, exp)
+	}
+	if len(c.items) > 0 {
+		stats.OldestEntry = time.Unix(0, oldest).Add(-c.duration)
+		stats.NewestEntry = time.Unix(0, newest).Add(-c.duration)
+	}
+	return stats
+}
+
+func (c *Cache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *Cache[T]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+}
+
+func (c *Cache[T]) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+}
+
+func (c *Cache[T]) evict() {
+	for c.order.Len() > 0 {
+		overEntries := c.opts.maxEntries > 0 && c.order.Len() > c.opts.maxEntries
+		overBytes := c.opts.maxBytes > 0 && c.bytes > c.opts.maxBytes
+		if !overEntries && !overBytes {
+			return
+		}
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache[T]) remove(elem *list.Element) {
+	e := c.order.Remove(elem).(*entry[T])
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
+
+func (c *Cache[T]) janitor() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.opts.janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.deleteExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *Cache[T]) deleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := time.Now().Add(-c.retention()).UnixNano()
+	for _, elem := range c.items {
+		if cutoff > elem.Value.(*entry[T]).item.Expiration {
+			c.remove(elem)
+			c.stats.Expirations++
+		}
+	}
+}
+
+func (c *Cache[T]) retention() time.Duration {
+	return max(c.opts.staleWhileRevalidate, c.opts.staleIfError)
+}
+
+const snapshotVersion = 1
+
+type snapshotHeader struct {
+	Version int
+	Type    string
+}
+
+type snapshotEntry[T any] struct {
+	Key        string
+	Value      T
+	Expiration int64
+}
+
+func (c *Cache[T]) Save(w io.Writer) error {
+	c.mu.Lock()
+	entries := make([]snapshotEntry[T], 0, len(c.items))
+	for elem := c.order.Back(); elem != nil; elem = elem.Prev() {
+		e := elem.Value.(*entry[T])
+		entries = append(entries, snapshotEntry[T]{Key: e.key, Value: e.item.Value, Expiration: e.item.Expiration})
+	}
+	c.mu.Unlock()
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, Type: c.typeName()}); err != nil {
+		return fmt.Errorf("failed to encode snapshot header: %w", err)
+	}
+	if err := enc.Encode(entries); err != nil {
+		return fmt.Errorf("failed to encode snapshot entries: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache[T]) Load(r io.Reader) (int, error) {
+	dec := gob.NewDecoder(r)
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("failed to decode snapshot header: %w", err)
+	}
+	if header.Version != snapshotVersion || header.Type != c.typeName() {
+		return 0, fmt.Errorf("snapshot mismatch: got version %d of %s, want version %d of %s", header.Version, header.Type, snapshotVersion, c.typeName())
+	}
+
+	var entries []snapshotEntry[T]
+	if err := dec.Decode(&entries); err != nil {
+		return 0, fmt.Errorf("failed to decode snapshot entries: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	loaded := 0
+	for _, se := range entries {
+		if now > se.Expiration {
+			continue
+		}
+		if _, found := c.items[se.Key]; found {
+			continue
+		}
+
+		size := 0
+		if sizer, ok := any(se.Value).(Sizer); ok {
+			size = sizer.Size()
+		}
+		if c.opts.maxBytes > 0 && size > c.opts.maxBytes {
+			continue
+		}
+		c.items[se.Key] = c.order.PushFront(&entry[T]{
+			key:  se.Key,
+			item: CacheItem[T]{Value: se.Value, Expiration: se.Expiration},
+			size: size,
+		})
+		c.bytes += size
+		loaded++
+	}
+	c.evict()
+
+	return loaded, nil
+}
+
+func (c *Cache[T]) typeName() string {
+	var zero T
+	return fmt.Sprintf("%T", zero)
 }
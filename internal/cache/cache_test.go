@@ -0,0 +1,464 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// sized is a value that reports its own size, for the byte budget.
+type sized int
+
+func (s sized) Size() int { return int(s) }
+
+// keys returns the cached keys from most to least recently used.
+func keys[T any](c *Cache[T]) []string {
+	var got []string
+	c.Range(func(key string, _ T) bool {
+		got = append(got, key)
+		return true
+	})
+	return got
+}
+
+// expireAt moves the expiration of a cached key to t.
+func expireAt[T any](c *Cache[T], key string, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key].Value.(*entry[T]).item.Expiration = t.UnixNano()
+}
+
+func TestLRUEviction(t *testing.T) {
+	tests := []struct {
+		name      string
+		ops       func(c *Cache[int])
+		want      []string
+		evictions uint64
+	}{
+		{
+			name: "oldest goes first",
+			ops: func(c *Cache[int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("c", 3)
+				c.Set("d", 4)
+			},
+			want:      []string{"d", "c", "b"},
+			evictions: 1,
+		},
+		{
+			name: "get marks an entry used",
+			ops: func(c *Cache[int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("c", 3)
+				c.Get("a")
+				c.Set("d", 4)
+			},
+			want:      []string{"d", "a", "c"},
+			evictions: 1,
+		},
+		{
+			name: "set of an existing key marks it used",
+			ops: func(c *Cache[int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("c", 3)
+				c.Set("a", 10)
+				c.Set("d", 4)
+			},
+			want:      []string{"d", "a", "c"},
+			evictions: 1,
+		},
+		{
+			name: "peek and has leave recency alone",
+			ops: func(c *Cache[int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("c", 3)
+				c.Peek("a")
+				c.Has("a")
+				c.Set("d", 4)
+			},
+			want:      []string{"d", "c", "b"},
+			evictions: 1,
+		},
+		{
+			name: "delete frees a slot",
+			ops: func(c *Cache[int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("c", 3)
+				c.Delete("b")
+				c.Set("d", 4)
+			},
+			want: []string{"d", "c", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[int](time.Minute, WithMaxEntries(3))
+			defer c.Close()
+			tt.ops(c)
+
+			if got := keys(c); !slices.Equal(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+			if got := c.Stats().Evictions; got != tt.evictions {
+				t.Errorf("evictions = %d, want %d", got, tt.evictions)
+			}
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	tests := []struct {
+		name  string
+		ops   func(c *Cache[sized])
+		want  []string
+		bytes int
+	}{
+		{
+			name: "within budget",
+			ops: func(c *Cache[sized]) {
+				c.Set("a", 4)
+				c.Set("b", 4)
+			},
+			want:  []string{"b", "a"},
+			bytes: 8,
+		},
+		{
+			name: "over budget evicts the oldest",
+			ops: func(c *Cache[sized]) {
+				c.Set("a", 4)
+				c.Set("b", 4)
+				c.Set("c", 4)
+			},
+			want:  []string{"c", "b"},
+			bytes: 8,
+		},
+		{
+			name: "replacing an entry updates its size",
+			ops: func(c *Cache[sized]) {
+				c.Set("a", 4)
+				c.Set("b", 4)
+				c.Set("a", 1)
+				c.Set("c", 5)
+			},
+			want:  []string{"c", "a", "b"},
+			bytes: 10,
+		},
+		{
+			name: "one large entry evicts several",
+			ops: func(c *Cache[sized]) {
+				c.Set("a", 3)
+				c.Set("b", 3)
+				c.Set("c", 3)
+				c.Set("d", 9)
+			},
+			want:  []string{"d"},
+			bytes: 9,
+		},
+		{
+			name: "entry larger than the budget is not stored",
+			ops: func(c *Cache[sized]) {
+				c.Set("a", 4)
+				c.Set("b", 4)
+				c.Set("big", 11)
+			},
+			want:  []string{"b", "a"},
+			bytes: 8,
+		},
+		{
+			name: "too large a replacement drops the old value",
+			ops: func(c *Cache[sized]) {
+				c.Set("a", 4)
+				c.Set("b", 4)
+				c.Set("a", 11)
+			},
+			want:  []string{"b"},
+			bytes: 4,
+		},
+		{
+			name: "delete releases bytes",
+			ops: func(c *Cache[sized]) {
+				c.Set("a", 4)
+				c.Set("b", 4)
+				c.Delete("a")
+			},
+			want:  []string{"b"},
+			bytes: 4,
+		},
+		{
+			name: "delete prefix releases bytes",
+			ops: func(c *Cache[sized]) {
+				c.Set("item:1", 2)
+				c.Set("item:2", 2)
+				c.Set("user:a", 3)
+				c.DeletePrefix("item:")
+			},
+			want:  []string{"user:a"},
+			bytes: 3,
+		},
+		{
+			name: "purge releases everything",
+			ops: func(c *Cache[sized]) {
+				c.Set("a", 4)
+				c.Set("b", 4)
+				c.Purge()
+			},
+			bytes: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[sized](time.Minute, WithMaxBytes(10))
+			defer c.Close()
+			tt.ops(c)
+
+			if got := keys(c); !slices.Equal(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+			stats := c.Stats()
+			if stats.Bytes != tt.bytes {
+				t.Errorf("bytes = %d, want %d", stats.Bytes, tt.bytes)
+			}
+			if stats.Entries != len(tt.want) || c.Len() != len(tt.want) {
+				t.Errorf("entries = %d, len = %d, want %d", stats.Entries, c.Len(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		opts      []Option
+		expiredBy time.Duration
+		kept      bool
+	}{
+		{name: "unexpired", expiredBy: -time.Second, kept: true},
+		{name: "expired", expiredBy: time.Second, kept: false},
+		{name: "within stale-while-revalidate", opts: []Option{WithStaleWhileRevalidate(time.Minute)}, expiredBy: 30 * time.Second, kept: true},
+		{name: "past stale-while-revalidate", opts: []Option{WithStaleWhileRevalidate(time.Minute)}, expiredBy: 2 * time.Minute, kept: false},
+		{name: "within stale-if-error", opts: []Option{WithStaleWhileRevalidate(time.Second), WithStaleIfError(time.Hour)}, expiredBy: time.Minute, kept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[int](time.Minute, tt.opts...)
+			defer c.Close()
+			c.Set("a", 1)
+			expireAt(c, "a", now.Add(-tt.expiredBy))
+
+			c.deleteExpired()
+
+			if c.Has("a") != tt.kept {
+				t.Errorf("kept = %v, want %v", !tt.kept, tt.kept)
+			}
+			want := uint64(1)
+			if tt.kept {
+				want = 0
+			}
+			if got := c.Stats().Expirations; got != want {
+				t.Errorf("expirations = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	c := New[int](time.Millisecond, WithJanitor(5*time.Millisecond))
+	c.Set("a", 1)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("janitor did not remove the expired entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the janitor")
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	tests := []struct {
+		name      string
+		swr, sie  time.Duration
+		expiredBy time.Duration
+		want      Freshness
+	}{
+		{name: "before expiry", swr: time.Minute, sie: 10 * time.Minute, expiredBy: -time.Second, want: Fresh},
+		{name: "just expired", swr: time.Minute, sie: 10 * time.Minute, expiredBy: time.Second, want: Stale},
+		{name: "end of revalidate window", swr: time.Minute, sie: 10 * time.Minute, expiredBy: 59 * time.Second, want: Stale},
+		{name: "past revalidate window", swr: time.Minute, sie: 10 * time.Minute, expiredBy: 61 * time.Second, want: Expired},
+		{name: "end of error window", swr: time.Minute, sie: 10 * time.Minute, expiredBy: 599 * time.Second, want: Expired},
+		{name: "past error window", swr: time.Minute, sie: 10 * time.Minute, expiredBy: 601 * time.Second, want: Miss},
+		{name: "error window shorter than revalidate window", swr: 10 * time.Minute, sie: time.Minute, expiredBy: 5 * time.Minute, want: Stale},
+		{name: "past both shorter error window", swr: 10 * time.Minute, sie: time.Minute, expiredBy: 11 * time.Minute, want: Miss},
+		{name: "no windows", expiredBy: time.Second, want: Miss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[int](time.Minute, WithStaleWhileRevalidate(tt.swr), WithStaleIfError(tt.sie))
+			defer c.Close()
+			c.Set("a", 7)
+			expireAt(c, "a", time.Now().Add(-tt.expiredBy))
+
+			value, state := c.GetStale("a")
+			if state != tt.want {
+				t.Fatalf("GetStale state = %v, want %v", state, tt.want)
+			}
+			wantValue := 7
+			if tt.want == Miss {
+				wantValue = 0
+			}
+			if value != wantValue {
+				t.Errorf("GetStale value = %d, want %d", value, wantValue)
+			}
+
+			// Get only ever returns fresh values.
+			if _, ok := c.Get("a"); ok != (tt.want == Fresh) {
+				t.Errorf("Get ok = %v, want %v", ok, tt.want == Fresh)
+			}
+
+			stats := c.Stats()
+			var hits, stale, misses uint64
+			switch tt.want {
+			case Fresh:
+				hits = 2
+			case Stale:
+				stale, misses = 1, 1
+			default:
+				misses = 2
+			}
+			if stats.Hits != hits || stats.StaleHits != stale || stats.Misses != misses {
+				t.Errorf("hits, stale hits, misses = %d, %d, %d, want %d, %d, %d", stats.Hits, stats.StaleHits, stats.Misses, hits, stale, misses)
+			}
+		})
+	}
+}
+
+func TestGetStaleMissingKey(t *testing.T) {
+	c := New[int](time.Minute, WithStaleIfError(time.Hour))
+	defer c.Close()
+	if value, state := c.GetStale("missing"); state != Miss || value != 0 {
+		t.Errorf("GetStale = %d, %v, want 0, miss", value, state)
+	}
+	if got := c.Stats().Misses; got != 1 {
+		t.Errorf("misses = %d, want 1", got)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	src := New[string](time.Minute)
+	defer src.Close()
+	src.Set("a", "one")
+	src.Set("b", "two")
+	src.Set("c", "three")
+	src.Set("gone", "expired")
+	expireAt(src, "gone", time.Now().Add(-time.Second))
+	src.Get("a")
+
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := New[string](time.Minute, WithMaxEntries(3))
+	defer dst.Close()
+	dst.Set("b", "kept")
+	loaded, err := dst.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expired entries are skipped and live ones are never overwritten. The
+	// rest keep their order, and the entry limit still applies.
+	if loaded != 2 {
+		t.Errorf("loaded = %d, want 2", loaded)
+	}
+	if got, want := keys(dst), []string{"a", "c", "b"}; !slices.Equal(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+	if v, _ := dst.Peek("b"); v != "kept" {
+		t.Errorf("b = %q, want the live value", v)
+	}
+
+	srcExp := src.items["a"].Value.(*entry[string]).item.Expiration
+	dstExp := dst.items["a"].Value.(*entry[string]).item.Expiration
+	if srcExp != dstExp {
+		t.Errorf("expiration changed from %d to %d", srcExp, dstExp)
+	}
+}
+
+func TestLoadRejectsBadSnapshots(t *testing.T) {
+	snapshot := func(write func(*gob.Encoder)) []byte {
+		var buf bytes.Buffer
+		write(gob.NewEncoder(&buf))
+		return buf.Bytes()
+	}
+	valid := func() []byte {
+		c := New[int](time.Minute)
+		defer c.Close()
+		for i := range 20 {
+			c.Set(strconv.Itoa(i), i)
+		}
+		var buf bytes.Buffer
+		if err := c.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"corrupt", []byte("not a gob snapshot at all")},
+		{"truncated header", valid[:5]},
+		{"truncated entries", valid[:len(valid)-10]},
+		{"newer version", snapshot(func(enc *gob.Encoder) {
+			enc.Encode(snapshotHeader{Version: snapshotVersion + 1, Type: "int"})
+			enc.Encode([]snapshotEntry[int]{{Key: "a", Value: 1, Expiration: time.Now().Add(time.Hour).UnixNano()}})
+		})},
+		{"other value type", snapshot(func(enc *gob.Encoder) {
+			enc.Encode(snapshotHeader{Version: snapshotVersion, Type: "string"})
+			enc.Encode([]snapshotEntry[string]{{Key: "a", Value: "x", Expiration: time.Now().Add(time.Hour).UnixNano()}})
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[int](time.Minute)
+			defer c.Close()
+			c.Set("live", 1)
+
+			if n, err := c.Load(bytes.NewReader(tt.data)); err == nil {
+				t.Fatalf("Load = %d, nil, want an error", n)
+			}
+			if got := keys(c); !slices.Equal(got, []string{"live"}) {
+				t.Errorf("keys after failed load = %v, want [live]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type Snapshotter interface {
+	Save(w io.Writer) error
+	Load(r io.Reader) (int, error)
+}
+
+type Persister struct {
+	dir      string
+	interval time.Duration
+	logger   *slog.Logger
+	names    []string
+	caches   map[string]Snapshotter
+	mu       sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewPersister(dir string, interval time.Duration, logger *slog.Logger) *Persister {
+	return &Persister{
+		dir:      dir,
+		interval: interval,
+		logger:   logger,
+		caches:   make(map[string]Snapshotter),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *Persister) Register(name string, s Snapshotter) {
+	if _, found := p.caches[name]; !found {
+		p.names = append(p.names, name)
+	}
+	p.caches[name] = s
+}
+
+func (p *Persister) Restore() {
+	for _, name := range p.names {
+		path := p.path(name)
+
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			p.logger.Warn("failed to open cache snapshot", "cache", name, "path", path, "error", err)
+			continue
+		}
+
+		loaded, err := p.caches[name].Load(file)
+		file.Close()
+		if err != nil {
+			p.logger.Warn("ignoring unreadable cache snapshot", "cache", name, "path", path, "error", err)
+			continue
+		}
+
+		p.logger.Info("restored cache snapshot", "cache", name, "entries", loaded)
+	}
+}
+
+func (p *Persister) Start() {
+	defer close(p.done)
+
+	p.logger.Info("starting cache snapshot persister", "dir", p.dir, "interval", p.interval)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.SaveAll()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Stop halts periodic snapshots and writes a final one.
+func (p *Persister) Stop() {
+	close(p.stop)
+	<-p.done
+
+	p.logger.Info("writing final cache snapshot")
+	p.SaveAll()
+}
+
+func (p *Persister) SaveAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.MkdirAll(p.dir, 0o755); err != nil {
+		p.logger.Error("failed to create cache snapshot directory", "dir", p.dir, "error", err)
+		return
+	}
+
+	for _, name := range p.names {
+		if err := p.save(name); err != nil {
+			p.logger.Error("failed to write cache snapshot", "cache", name, "error", err)
+		}
+	}
+}
+
+func (p *Persister) save(name string) error {
+	tmp, err := os.CreateTemp(p.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := p.caches[name].Save(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), p.path(name)); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	return nil
+}
+
+func (p *Persister) path(name string) string {
+	return filepath.Join(p.dir, name+".gob")
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersisterRestore(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	items, users := New[int](time.Minute), New[string](time.Minute)
+	defer items.Close()
+	defer users.Close()
+	items.Set("item:1", 1)
+	users.Set("user:a", "a")
+
+	p := NewPersister(dir, time.Hour, logger)
+	p.Register("items", items)
+	p.Register("users", users)
+	p.SaveAll()
+
+	tests := []struct {
+		name      string
+		prepare   func(t *testing.T)
+		wantItems bool
+		wantUsers bool
+	}{
+		{name: "both restored", wantItems: true, wantUsers: true},
+		{
+			name: "missing snapshot skipped",
+			prepare: func(t *testing.T) {
+				os.Remove(filepath.Join(dir, "items.gob"))
+			},
+			wantUsers: true,
+		},
+		{
+			name: "corrupt snapshot ignored",
+			prepare: func(t *testing.T) {
+				if err := os.WriteFile(filepath.Join(dir, "users.gob"), []byte("garbage"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	// Each case builds on the files left by the previous one.
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare(t)
+			}
+			items, users := New[int](time.Minute), New[string](time.Minute)
+			defer items.Close()
+			defer users.Close()
+
+			p := NewPersister(dir, time.Hour, logger)
+			p.Register("items", items)
+			p.Register("users", users)
+			p.Restore()
+
+			if got := items.Has("item:1"); got != tt.wantItems {
+				t.Errorf("items restored = %v, want %v", got, tt.wantItems)
+			}
+			if got := users.Has("user:a"); got != tt.wantUsers {
+				t.Errorf("users restored = %v, want %v", got, tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestPersisterStopWritesFinalSnapshot(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	c := New[int](time.Minute)
+	defer c.Close()
+	p := NewPersister(dir, time.Hour, logger)
+	p.Register("items", c)
+	go p.Start()
+
+	c.Set("item:1", 1)
+	p.Stop()
+
+	restored := New[int](time.Minute)
+	defer restored.Close()
+	file, err := os.Open(filepath.Join(dir, "items.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if n, err := restored.Load(file); err != nil || n != 1 {
+		t.Fatalf("Load = %d, %v, want 1, nil", n, err)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
@@ -3,59 +3,243 @@ package cache
 import (
 	"context"
 	"log/slog"
+	"math/rand/v2"
+	"sync"
 	"time"
+
+	"hackernews/internal/config"
 )
 
 type IDListFetcher interface {
-	GetStoryIDs(ctx context.Context, storyType string) ([]int, error)
+	RefreshStoryIDs(ctx context.Context, storyType string) ([]int, error)
 }
 
-type Refresher struct {
-	client   IDListFetcher
-	logger   *slog.Logger
+type UpdateApplier interface {
+	ApplyUpdates(ctx context.Context) (int, error)
+}
+
+type Prewarmer interface {
+	Prewarm(ctx context.Context, storyType string, opts config.PrewarmConfig) (int, error)
+}
+
+type FeedStatus struct {
+	Name        string        `json:"name"`
+	Interval    time.Duration `json:"interval"`
+	LastSuccess time.Time     `json:"last_success"`
+	LastError   string        `json:"last_error,omitempty"`
+	LastErrorAt time.Time     `json:"last_error_at"`
+	Entries     int           `json:"entries"`
+}
+
+type feed struct {
+	name     string
 	interval time.Duration
-	stop     chan struct{}
+	trigger  chan struct{}
+}
+
+type Refresher struct {
+	client          IDListFetcher
+	logger          *slog.Logger
+	feeds           []*feed
+	jitter          float64
+	updates         UpdateApplier
+	updatesInterval time.Duration
+	prewarmer       Prewarmer
+	prewarm         config.PrewarmConfig
+	mu              sync.Mutex
+	status          map[string]*FeedStatus
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+}
+
+type RefresherOption func(*Refresher)
+
+func WithUpdates(applier UpdateApplier, interval time.Duration) RefresherOption {
+	return func(r *Refresher) {
+		r.updates = applier
+		r.updatesInterval = interval
+	}
+}
+
+func WithPrewarm(prewarmer Prewarmer, opts config.PrewarmConfig) RefresherOption {
+	return func(r *Refresher) {
+		r.prewarmer = prewarmer
+		r.prewarm = opts
+	}
 }
 
-func NewRefresher(client IDListFetcher, logger *slog.Logger, interval time.Duration) *Refresher {
-	return &Refresher{
-		client:   client,
-		logger:   logger,
-		interval: interval,
-		stop:     make(chan struct{}),
+func NewRefresher(client IDListFetcher, logger *slog.Logger, cfg config.RefresherConfig, opts ...RefresherOption) *Refresher {
+	r := &Refresher{
+		client: client,
+		logger: logger,
+		jitter: cfg.Jitter,
+		status: make(map[string]*FeedStatus),
 	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	for _, fc := range cfg.Feeds {
+		interval := fc.Interval
+		if interval <= 0 {
+			interval = cfg.Interval
+		}
+		r.feeds = append(r.feeds, &feed{
+			name:     fc.Name,
+			interval: interval,
+			trigger:  make(chan struct{}, 1),
+		})
+		r.status[fc.Name] = &FeedStatus{Name: fc.Name, Interval: interval}
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func (r *Refresher) Start() {
-	r.logger.Info("starting background ID list cache refresher", "interval", r.interval)
-	ticker := time.NewTicker(r.interval)
-	defer ticker.Stop()
+	r.logger.Info("starting background ID list cache refresher", "feeds", len(r.feeds))
+
+	for _, f := range r.feeds {
+		r.wg.Add(1)
+		go r.runFeed(f)
+	}
+
+	if r.updates != nil && r.updatesInterval > 0 {
+		r.wg.Add(1)
+		go r.runUpdates()
+	}
+
+	<-r.ctx.Done()
+	r.wg.Wait()
+	r.logger.Info("stopping background cache refresher")
+}
+
+// Stop cancels any refresh in flight and ends the loops started by Start.
+func (r *Refresher) Stop() {
+	r.cancel()
+}
+
+// RefreshNow schedules an immediate refresh of the named feed. It reports
+// false if the feed is not configured.
+func (r *Refresher) RefreshNow(name string) bool {
+	for _, f := range r.feeds {
+		if f.name == name {
+			select {
+			case f.trigger <- struct{}{}:
+			default:
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Refresher) Status() []FeedStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]FeedStatus, 0, len(r.feeds))
+	for _, f := range r.feeds {
+		statuses = append(statuses, *r.status[f.name])
+	}
+	return statuses
+}
+
+func (r *Refresher) runFeed(f *feed) {
+	defer r.wg.Done()
+
+	r.refresh(f)
+
+	timer := time.NewTimer(r.withJitter(f.interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-f.trigger:
+		case <-r.ctx.Done():
+			return
+		}
+
+		r.refresh(f)
+		timer.Reset(r.withJitter(f.interval))
+	}
+}
+
+func (r *Refresher) runUpdates() {
+	defer r.wg.Done()
 
-	r.refresh()
+	r.logger.Info("polling upstream updates feed", "interval", r.updatesInterval)
+	ticker := time.NewTicker(r.updatesInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			r.refresh()
-		case <-r.stop:
-			r.logger.Info("stopping background cache refresher")
+			r.applyUpdates()
+		case <-r.ctx.Done():
 			return
 		}
 	}
 }
 
-func (r *Refresher) Stop() {
-	close(r.stop)
+func (r *Refresher) withJitter(interval time.Duration) time.Duration {
+	if r.jitter <= 0 {
+		return interval
+	}
+	spread := float64(interval) * min(r.jitter, 1)
+	return time.Duration(float64(interval) - spread + 2*rand.Float64()*spread)
 }
 
-func (r *Refresher) refresh() {
-	r.logger.Info("performing background ID list cache refresh")
-	storyTypes := []string{"top", "new", "ask", "show", "job"}
-	ctx := context.Background()
+func (r *Refresher) refresh(f *feed) {
+	ctx, cancel := context.WithTimeout(r.ctx, f.interval)
+	defer cancel()
+
+	r.logger.Info("performing background ID list cache refresh", "type", f.name)
+	ids, err := r.client.RefreshStoryIDs(ctx, f.name)
+	if r.ctx.Err() != nil {
+		return
+	}
+
+	r.mu.Lock()
+	status := r.status[f.name]
+	if err != nil {
+		status.LastError = err.Error()
+		status.LastErrorAt = time.Now()
+	} else {
+		status.LastSuccess = time.Now()
+		status.LastError = ""
+		status.Entries = len(ids)
+	}
+	r.mu.Unlock()
 
-	for _, storyType := range storyTypes {
-		if _, err := r.client.GetStoryIDs(ctx, storyType); err != nil {
-			r.logger.Error("failed to refresh ID list cache", "type", storyType, "error", err)
+	if err != nil {
+		r.logger.Error("failed to refresh ID list cache", "type", f.name, "error", err)
+		return
+	}
+
+	if r.prewarmer != nil && r.prewarm.Pages > 0 {
+		warmed, err := r.prewarmer.Prewarm(ctx, f.name, r.prewarm)
+		if err != nil {
+			r.logger.Error("failed to prewarm items", "type", f.name, "error", err)
+			return
 		}
+		r.logger.Info("prewarmed items", "type", f.name, "items", warmed)
+	}
+}
+
+func (r *Refresher) applyUpdates() {
+	ctx, cancel := context.WithTimeout(r.ctx, r.updatesInterval)
+	defer cancel()
+
+	updated, err := r.updates.ApplyUpdates(ctx)
+	if r.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		r.logger.Error("failed to apply upstream updates", "error", err)
+		return
 	}
+	r.logger.Info("applied upstream updates", "entries", updated)
 }
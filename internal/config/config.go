@@ -4,30 +4,163 @@ import "time"
 
 type Config struct {
 	Port          int
+	Server        ServerConfig
 	Cache         CacheConfig
 	HackerNewsAPI HackerNewsAPIConfig
+	Refresher     RefresherConfig
+	Stream        StreamConfig
+	Admin         AdminConfig
+	Gemini        GeminiConfig
+}
+
+// ServerConfig holds the main HTTP server's settings. The timeouts also apply
+// to the admin server.
+type ServerConfig struct {
+	PublicURL    string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+type RefresherConfig struct {
+	Interval        time.Duration
+	Feeds           []FeedConfig
+	Jitter          float64
+	UpdatesInterval time.Duration
+	Prewarm         PrewarmConfig
+}
+
+type FeedConfig struct {
+	Name     string
+	Interval time.Duration
+}
+
+type PrewarmConfig struct {
+	Pages    int
+	Comments bool
+	Workers  int
+}
+
+type StreamConfig struct {
+	Enabled bool
+	Feeds   []string
+	MaxItem bool
+	Items   int
+}
+
+type AdminConfig struct {
+	Addr  string
+	Token string
+}
+
+type GeminiConfig struct {
+	Addr         string
+	CertFile     string
+	KeyFile      string
+	Hostname     string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
 }
 
 type CacheConfig struct {
-	ItemTTL time.Duration
+	ItemTTL              time.Duration
+	MaxItems             int
+	MaxItemBytes         int
+	MaxUsers             int
+	JanitorInterval      time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	SnapshotDir          string
+	SnapshotInterval     time.Duration
 }
 
 type HackerNewsAPIConfig struct {
 	BaseURL      string
 	ItemsPerPage int
 	WorkerCount  int
+	Retry        RetryConfig
+	Comments     CommentsConfig
+}
+
+type CommentsConfig struct {
+	PerPage     int
+	MaxDepth    int
+	MaxComments int
+	Timeout     time.Duration
+}
+
+type RetryConfig struct {
+	MaxAttempts          int
+	BaseDelay            time.Duration
+	MaxDelay             time.Duration
+	Jitter               float64
+	RetryableStatusCodes []int
 }
 
 func New() *Config {
 	return &Config{
 		Port: 3000,
+		Server: ServerConfig{
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  time.Minute,
+		},
 		Cache: CacheConfig{
-			ItemTTL: 2 * time.Minute,
+			ItemTTL:              2 * time.Minute,
+			MaxItems:             20000,
+			MaxItemBytes:         64 << 20,
+			MaxUsers:             2000,
+			JanitorInterval:      time.Minute,
+			StaleWhileRevalidate: time.Minute,
+			StaleIfError:         15 * time.Minute,
+			SnapshotInterval:     5 * time.Minute,
+		},
+		Refresher: RefresherConfig{
+			Interval: 90 * time.Second,
+			Feeds: []FeedConfig{
+				{Name: "top", Interval: 90 * time.Second},
+				{Name: "best", Interval: 5 * time.Minute},
+				{Name: "new", Interval: 30 * time.Second},
+				{Name: "ask", Interval: 2 * time.Minute},
+				{Name: "show", Interval: 2 * time.Minute},
+				{Name: "job", Interval: 10 * time.Minute},
+			},
+			Jitter:          0.1,
+			UpdatesInterval: 30 * time.Second,
+			Prewarm: PrewarmConfig{
+				Pages:   1,
+				Workers: 3,
+			},
+		},
+		Stream: StreamConfig{
+			Feeds:   []string{"top"},
+			MaxItem: true,
+			Items:   30,
+		},
+		Gemini: GeminiConfig{
+			CertFile:     "gemini.crt",
+			KeyFile:      "gemini.key",
+			Hostname:     "localhost",
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
 		},
 		HackerNewsAPI: HackerNewsAPIConfig{
 			BaseURL:      "https://hacker-news.firebaseio.com/v0",
 			ItemsPerPage: 30,
 			WorkerCount:  10,
+			Retry: RetryConfig{
+				MaxAttempts:          3,
+				BaseDelay:            200 * time.Millisecond,
+				MaxDelay:             2 * time.Second,
+				Jitter:               0.5,
+				RetryableStatusCodes: []int{429, 500, 502, 503, 504},
+			},
+			Comments: CommentsConfig{
+				PerPage:     50,
+				MaxDepth:    20,
+				MaxComments: 1000,
+				Timeout:     5 * time.Second,
+			},
 		},
 	}
 }
@@ -0,0 +1,318 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const envPrefix = "HN_"
+
+type Options struct {
+	ConfigFile  string
+	PrintConfig bool
+}
+
+// Load builds the configuration from defaults, then the JSON config file, then
+// HN_* environment variables, then command-line flags, each overriding the one
+// before. Every flag has a matching variable: --api.retry.max-attempts is read
+// from HN_API_RETRY_MAX_ATTEMPTS. The config file is named by --config or
+// HN_CONFIG and uses the nested layout printed by --print-config.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, *Options, error) {
+	opts := &Options{}
+	if err := newFlagSet(New(), opts).Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if opts.ConfigFile == "" {
+		opts.ConfigFile, _ = lookupEnv(configFileEnv)
+	}
+
+	cfg := New()
+	fs := newFlagSet(cfg, &Options{})
+
+	if opts.ConfigFile != "" {
+		if err := applyFile(fs, opts.ConfigFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var envErrs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if optionFlags[f.Name] {
+			return
+		}
+		name := EnvName(f.Name)
+		value, ok := lookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			envErrs = append(envErrs, fmt.Errorf("invalid value %q for %s: %w", value, name, err))
+		}
+	})
+	if err := errors.Join(envErrs...); err != nil {
+		return nil, nil, err
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, opts, nil
+}
+
+const configFileEnv = envPrefix + "CONFIG"
+
+var optionFlags = map[string]bool{"config": true, "print-config": true}
+
+func newFlagSet(cfg *Config, opts *Options) *flag.FlagSet {
+	fs := flag.NewFlagSet("hackernews", flag.ContinueOnError)
+	bind(fs, cfg)
+	fs.StringVar(&opts.ConfigFile, "config", "", "path to a JSON config file (also "+configFileEnv+")")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration as JSON and exit")
+	return fs
+}
+
+func applyFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var root map[string]any
+	if err := dec.Decode(&root); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var errs []error
+	var walk func(prefix string, node map[string]any)
+	walk = func(prefix string, node map[string]any) {
+		for key, value := range node {
+			name := prefix + key
+			if child, ok := value.(map[string]any); ok {
+				walk(name+".", child)
+				continue
+			}
+
+			f := fs.Lookup(name)
+			if f == nil || optionFlags[name] {
+				errs = append(errs, fmt.Errorf("unknown setting %q", name))
+				continue
+			}
+			if err := f.Value.Set(fileValue(value)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for %q: %w", name, err))
+			}
+		}
+	}
+	walk("", root)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func fileValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		parts := make([]string, len(v))
+		for i, elem := range v {
+			parts[i] = fileValue(elem)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flagName))
+}
+
+func bind(fs *flag.FlagSet, cfg *Config) {
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "HTTP listen port")
+	fs.StringVar(&cfg.Server.PublicURL, "server.public-url", cfg.Server.PublicURL, "base URL for absolute links in feeds and text pages, e.g. https://news.example.com (default taken from each request)")
+	fs.DurationVar(&cfg.Server.ReadTimeout, "server.read-timeout", cfg.Server.ReadTimeout, "time to read a request, for the HTTP and admin servers (0 for none)")
+	fs.DurationVar(&cfg.Server.WriteTimeout, "server.write-timeout", cfg.Server.WriteTimeout, "time to write a response, for the HTTP and admin servers (0 for none)")
+	fs.DurationVar(&cfg.Server.IdleTimeout, "server.idle-timeout", cfg.Server.IdleTimeout, "how long idle keep-alive connections stay open (0 uses the read timeout)")
+
+	fs.DurationVar(&cfg.Cache.ItemTTL, "cache.item-ttl", cfg.Cache.ItemTTL, "lifetime of cached ID lists; items and users live twice as long")
+	fs.IntVar(&cfg.Cache.MaxItems, "cache.max-items", cfg.Cache.MaxItems, "maximum cached items (0 for unbounded)")
+	fs.IntVar(&cfg.Cache.MaxItemBytes, "cache.max-item-bytes", cfg.Cache.MaxItemBytes, "approximate maximum bytes of cached items (0 for unbounded)")
+	fs.IntVar(&cfg.Cache.MaxUsers, "cache.max-users", cfg.Cache.MaxUsers, "maximum cached users (0 for unbounded)")
+	fs.DurationVar(&cfg.Cache.JanitorInterval, "cache.janitor-interval", cfg.Cache.JanitorInterval, "how often expired cache entries are purged")
+	fs.DurationVar(&cfg.Cache.StaleWhileRevalidate, "cache.stale-while-revalidate", cfg.Cache.StaleWhileRevalidate, "how long expired entries are served while refreshing")
+	fs.DurationVar(&cfg.Cache.StaleIfError, "cache.stale-if-error", cfg.Cache.StaleIfError, "how long expired entries are served when upstream fails")
+	fs.StringVar(&cfg.Cache.SnapshotDir, "cache.snapshot-dir", cfg.Cache.SnapshotDir, "directory for cache snapshots (empty disables persistence)")
+	fs.DurationVar(&cfg.Cache.SnapshotInterval, "cache.snapshot-interval", cfg.Cache.SnapshotInterval, "how often cache snapshots are written")
+
+	fs.StringVar(&cfg.HackerNewsAPI.BaseURL, "api.base-url", cfg.HackerNewsAPI.BaseURL, "Hacker News Firebase API base URL")
+	fs.IntVar(&cfg.HackerNewsAPI.ItemsPerPage, "api.items-per-page", cfg.HackerNewsAPI.ItemsPerPage, "stories per page")
+	fs.IntVar(&cfg.HackerNewsAPI.WorkerCount, "api.workers", cfg.HackerNewsAPI.WorkerCount, "concurrent upstream fetches per request")
+	fs.IntVar(&cfg.HackerNewsAPI.Retry.MaxAttempts, "api.retry.max-attempts", cfg.HackerNewsAPI.Retry.MaxAttempts, "attempts per upstream call")
+	fs.DurationVar(&cfg.HackerNewsAPI.Retry.BaseDelay, "api.retry.base-delay", cfg.HackerNewsAPI.Retry.BaseDelay, "delay before the first retry")
+	fs.DurationVar(&cfg.HackerNewsAPI.Retry.MaxDelay, "api.retry.max-delay", cfg.HackerNewsAPI.Retry.MaxDelay, "maximum delay between retries")
+	fs.Float64Var(&cfg.HackerNewsAPI.Retry.Jitter, "api.retry.jitter", cfg.HackerNewsAPI.Retry.Jitter, "fraction of each retry delay to randomize (0-1)")
+	fs.Var((*intList)(&cfg.HackerNewsAPI.Retry.RetryableStatusCodes), "api.retry.status-codes", "comma-separated upstream status codes to retry")
+	fs.IntVar(&cfg.HackerNewsAPI.Comments.PerPage, "api.comments.per-page", cfg.HackerNewsAPI.Comments.PerPage, "top-level comments per item page (0 for all)")
+	fs.IntVar(&cfg.HackerNewsAPI.Comments.MaxDepth, "api.comments.max-depth", cfg.HackerNewsAPI.Comments.MaxDepth, "maximum comment depth loaded (0 for unbounded)")
+	fs.IntVar(&cfg.HackerNewsAPI.Comments.MaxComments, "api.comments.max-comments", cfg.HackerNewsAPI.Comments.MaxComments, "maximum comments loaded per page (0 for unbounded)")
+	fs.DurationVar(&cfg.HackerNewsAPI.Comments.Timeout, "api.comments.timeout", cfg.HackerNewsAPI.Comments.Timeout, "time budget for loading a comment tree (0 for none)")
+
+	fs.DurationVar(&cfg.Refresher.Interval, "refresher.interval", cfg.Refresher.Interval, "default refresh interval for feeds without their own")
+	fs.Var((*feedList)(&cfg.Refresher.Feeds), "refresher.feeds", "comma-separated feeds to refresh, each optionally name=interval")
+	fs.Float64Var(&cfg.Refresher.Jitter, "refresher.jitter", cfg.Refresher.Jitter, "fraction of each refresh interval to randomize (0-1)")
+	fs.DurationVar(&cfg.Refresher.UpdatesInterval, "refresher.updates-interval", cfg.Refresher.UpdatesInterval, "how often the updates feed is polled (0 disables)")
+	fs.IntVar(&cfg.Refresher.Prewarm.Pages, "refresher.prewarm.pages", cfg.Refresher.Prewarm.Pages, "pages of each feed to prewarm (0 disables)")
+	fs.BoolVar(&cfg.Refresher.Prewarm.Comments, "refresher.prewarm.comments", cfg.Refresher.Prewarm.Comments, "also prewarm top-level comments")
+	fs.IntVar(&cfg.Refresher.Prewarm.Workers, "refresher.prewarm.workers", cfg.Refresher.Prewarm.Workers, "concurrent fetches used for prewarming")
+
+	fs.BoolVar(&cfg.Stream.Enabled, "stream.enabled", cfg.Stream.Enabled, "follow Firebase event streams")
+	fs.Var((*stringList)(&cfg.Stream.Feeds), "stream.feeds", "comma-separated feeds to stream")
+	fs.BoolVar(&cfg.Stream.MaxItem, "stream.max-item", cfg.Stream.MaxItem, "stream and fetch the newest item")
+	fs.IntVar(&cfg.Stream.Items, "stream.items", cfg.Stream.Items, "stories at the head of each streamed feed whose items are also streamed (0 disables)")
+
+	fs.StringVar(&cfg.Admin.Addr, "admin.addr", cfg.Admin.Addr, "separate listen address for the admin API")
+	fs.StringVar(&cfg.Admin.Token, "admin.token", cfg.Admin.Token, "bearer token required by the admin API")
+
+	fs.StringVar(&cfg.Gemini.Addr, "gemini.addr", cfg.Gemini.Addr, "listen address for the Gemini server (empty disables)")
+	fs.StringVar(&cfg.Gemini.CertFile, "gemini.cert", cfg.Gemini.CertFile, "Gemini TLS certificate, generated if missing")
+	fs.StringVar(&cfg.Gemini.KeyFile, "gemini.key", cfg.Gemini.KeyFile, "Gemini TLS private key, generated if missing")
+	fs.StringVar(&cfg.Gemini.Hostname, "gemini.hostname", cfg.Gemini.Hostname, "hostname the Gemini server answers for and puts in a generated certificate")
+	fs.DurationVar(&cfg.Gemini.ReadTimeout, "gemini.read-timeout", cfg.Gemini.ReadTimeout, "time for a Gemini client to send its request (0 for none)")
+	fs.DurationVar(&cfg.Gemini.WriteTimeout, "gemini.write-timeout", cfg.Gemini.WriteTimeout, "time to write a Gemini response (0 for none)")
+}
+
+var storyTypes = []string{"top", "new", "best", "ask", "show", "job"}
+
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Port > 0 && c.Port < 65536, "port must be between 1 and 65535, got %d", c.Port)
+	check(c.Admin.Token != redacted, "admin.token is the placeholder printed by --print-config; set a real token or remove it")
+	if c.Server.PublicURL != "" {
+		u, err := url.Parse(c.Server.PublicURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.RawQuery == "" && u.Fragment == "", "server.public-url must be an absolute http(s) URL without a query, got %q", c.Server.PublicURL)
+	}
+	check(c.Server.ReadTimeout >= 0, "server.read-timeout must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write-timeout must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle-timeout must not be negative")
+
+	check(c.Cache.ItemTTL > 0, "cache.item-ttl must be positive")
+	check(c.Cache.MaxItems >= 0, "cache.max-items must not be negative")
+	check(c.Cache.MaxItemBytes >= 0, "cache.max-item-bytes must not be negative")
+	check(c.Cache.MaxUsers >= 0, "cache.max-users must not be negative")
+	check(c.Cache.JanitorInterval >= 0, "cache.janitor-interval must not be negative")
+	check(c.Cache.StaleWhileRevalidate >= 0, "cache.stale-while-revalidate must not be negative")
+	check(c.Cache.StaleIfError >= 0, "cache.stale-if-error must not be negative")
+	check(c.Cache.SnapshotDir == "" || c.Cache.SnapshotInterval > 0, "cache.snapshot-interval must be positive when cache.snapshot-dir is set")
+
+	u, err := url.Parse(c.HackerNewsAPI.BaseURL)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "api.base-url must be an absolute http(s) URL, got %q", c.HackerNewsAPI.BaseURL)
+	check(c.HackerNewsAPI.ItemsPerPage > 0, "api.items-per-page must be positive")
+	check(c.HackerNewsAPI.WorkerCount > 0, "api.workers must be positive")
+	check(c.HackerNewsAPI.Retry.MaxAttempts > 0, "api.retry.max-attempts must be positive")
+	check(c.HackerNewsAPI.Retry.BaseDelay >= 0, "api.retry.base-delay must not be negative")
+	check(c.HackerNewsAPI.Retry.MaxDelay >= c.HackerNewsAPI.Retry.BaseDelay, "api.retry.max-delay must not be less than api.retry.base-delay")
+	check(c.HackerNewsAPI.Retry.Jitter >= 0 && c.HackerNewsAPI.Retry.Jitter <= 1, "api.retry.jitter must be between 0 and 1")
+	for _, code := range c.HackerNewsAPI.Retry.RetryableStatusCodes {
+		check(code >= 100 && code < 600, "api.retry.status-codes contains invalid status %d", code)
+	}
+	check(c.HackerNewsAPI.Comments.PerPage >= 0, "api.comments.per-page must not be negative")
+	check(c.HackerNewsAPI.Comments.MaxDepth >= 0, "api.comments.max-depth must not be negative")
+	check(c.HackerNewsAPI.Comments.MaxComments >= 0, "api.comments.max-comments must not be negative")
+	check(c.HackerNewsAPI.Comments.Timeout >= 0, "api.comments.timeout must not be negative")
+
+	check(c.Refresher.Interval > 0, "refresher.interval must be positive")
+	for _, feed := range c.Refresher.Feeds {
+		check(slices.Contains(storyTypes, feed.Name), "refresher.feeds contains unknown feed %q", feed.Name)
+		check(feed.Interval >= 0, "refresher.feeds interval for %q must not be negative", feed.Name)
+	}
+	check(c.Refresher.Jitter >= 0 && c.Refresher.Jitter <= 1, "refresher.jitter must be between 0 and 1")
+	check(c.Refresher.UpdatesInterval >= 0, "refresher.updates-interval must not be negative")
+	check(c.Refresher.Prewarm.Pages >= 0, "refresher.prewarm.pages must not be negative")
+	check(c.Refresher.Prewarm.Workers > 0, "refresher.prewarm.workers must be positive")
+
+	check(c.Stream.Items >= 0, "stream.items must not be negative")
+	for _, feed := range c.Stream.Feeds {
+		check(slices.Contains(storyTypes, feed), "stream.feeds contains unknown feed %q", feed)
+	}
+
+	if c.Gemini.Addr != "" {
+		check(c.Gemini.CertFile != "" && c.Gemini.KeyFile != "", "gemini.cert and gemini.key are required when gemini.addr is set")
+		check(c.Gemini.Hostname != "", "gemini.hostname is required when gemini.addr is set")
+		check(c.Gemini.ReadTimeout >= 0, "gemini.read-timeout must not be negative")
+		check(c.Gemini.WriteTimeout >= 0, "gemini.write-timeout must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+// redacted stands in for the admin token in WriteJSON output. Validate refuses
+// it, so a config file copied from that output can't enable the admin routes
+// with a publicly known token.
+const redacted = "REDACTED"
+
+// WriteJSON writes the configuration as nested JSON keyed by flag name, with
+// durations in time.ParseDuration form and the admin token redacted.
+func (c *Config) WriteJSON(w io.Writer) error {
+	root := map[string]any{}
+	for name, value := range c.settings() {
+		if s, ok := value.(string); ok && name == "admin.token" && s != "" {
+			value = redacted
+		}
+
+		parts := strings.Split(name, ".")
+		node := root
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = value
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(root)
+}
+
+func (c *Config) settings() map[string]any {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	bind(fs, c)
+
+	values := map[string]any{}
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.(flag.Getter).Get()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		values[f.Name] = value
+	})
+	return values
+}
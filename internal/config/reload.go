@@ -0,0 +1,54 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// reloadable lists the settings, or setting prefixes ending in ".", that a
+// running server can pick up without a restart. Everything else, including
+// listen addresses and the server.* and gemini.* timeouts, is fixed once the
+// listeners start.
+var reloadable = []string{
+	"api.items-per-page",
+	"api.workers",
+	"api.retry.",
+	"api.comments.",
+	"admin.token",
+	"server.public-url",
+}
+
+func Reloadable(name string) bool {
+	return slices.ContainsFunc(reloadable, func(r string) bool {
+		return name == r || strings.HasSuffix(r, ".") && strings.HasPrefix(name, r)
+	})
+}
+
+// Diff returns the names of the settings that differ between old and new,
+// sorted by name.
+func Diff(old, new *Config) []string {
+	before, after := old.settings(), new.settings()
+
+	var changed []string
+	for name, value := range after {
+		if !reflect.DeepEqual(before[name], value) {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// Merge returns a copy of c with the reloadable settings taken from next.
+func (c *Config) Merge(next *Config) *Config {
+	merged := *c
+	merged.HackerNewsAPI.ItemsPerPage = next.HackerNewsAPI.ItemsPerPage
+	merged.HackerNewsAPI.WorkerCount = next.HackerNewsAPI.WorkerCount
+	merged.HackerNewsAPI.Retry = next.HackerNewsAPI.Retry
+	merged.HackerNewsAPI.Retry.RetryableStatusCodes = slices.Clone(next.HackerNewsAPI.Retry.RetryableStatusCodes)
+	merged.HackerNewsAPI.Comments = next.HackerNewsAPI.Comments
+	merged.Admin.Token = next.Admin.Token
+	merged.Server.PublicURL = next.Server.PublicURL
+	return &merged
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type intList []int
+
+func (l *intList) String() string {
+	if l == nil {
+		return ""
+	}
+	parts := make([]string, len(*l))
+	for i, n := range *l {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *intList) Set(s string) error {
+	var values []int
+	for _, part := range splitList(s) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", part)
+		}
+		values = append(values, n)
+	}
+	*l = values
+	return nil
+}
+
+func (l *intList) Get() any {
+	return []int(*l)
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = splitList(s)
+	return nil
+}
+
+func (l *stringList) Get() any {
+	return []string(*l)
+}
+
+// feedList parses "top=90s,new,job=10m"; feeds without an interval use the
+// refresher default.
+type feedList []FeedConfig
+
+func (l *feedList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(l.Get().([]string), ",")
+}
+
+func (l *feedList) Set(s string) error {
+	var feeds []FeedConfig
+	for _, part := range splitList(s) {
+		name, interval, hasInterval := strings.Cut(part, "=")
+		feed := FeedConfig{Name: strings.TrimSpace(name)}
+		if hasInterval {
+			d, err := time.ParseDuration(strings.TrimSpace(interval))
+			if err != nil {
+				return fmt.Errorf("invalid interval for feed %q: %w", feed.Name, err)
+			}
+			feed.Interval = d
+		}
+		feeds = append(feeds, feed)
+	}
+	*l = feeds
+	return nil
+}
+
+func (l *feedList) Get() any {
+	parts := make([]string, len(*l))
+	for i, feed := range *l {
+		parts[i] = feed.Name
+		if feed.Interval > 0 {
+			parts[i] += "=" + feed.Interval.String()
+		}
+	}
+	return parts
+}
+
+func splitList(s string) []string {
+	var parts []string
+	for part := range strings.SplitSeq(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,332 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxRequestLen = 1024
+	maxMetaLen    = 1024
+)
+
+// ErrServerClosed is returned by ListenAndServe after Shutdown.
+var ErrServerClosed = errors.New("gemini: server closed")
+
+type requestKey struct{}
+
+// IsRequest reports whether ctx belongs to a request from a Gemini server.
+func IsRequest(ctx context.Context) bool {
+	return ctx.Value(requestKey{}) != nil
+}
+
+// Server answers Gemini requests by running them through an http.Handler as
+// GET requests, marked so that IsRequest reports true for them, and
+// translating the response status to a Gemini status line.
+type Server struct {
+	Addr      string
+	Handler   http.Handler
+	TLSConfig *tls.Config
+	Logger    *slog.Logger
+
+	// Hostname is the host requests must be for; requests for other hosts
+	// are refused as proxy requests. Empty accepts any host.
+	Hostname string
+
+	// ReadTimeout bounds the TLS handshake and request line. WriteTimeout is
+	// the deadline of the request context, and then bounds writing the
+	// response. Zero means no timeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func (s *Server) ListenAndServe() error {
+	ln, err := tls.Listen("tcp", s.Addr, s.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.Addr, err)
+	}
+	return s.Serve(ln)
+}
+
+// Serve answers requests on ln, a listener from tls.Listen, until Shutdown.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	s.listener = ln
+	s.conns = make(map[net.Conn]struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	backoff := 5 * time.Millisecond
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			s.Logger.Error("gemini accept error", "error", err, "retry", backoff)
+			time.Sleep(backoff)
+			backoff = min(2*backoff, time.Second)
+			continue
+		}
+		backoff = 5 * time.Millisecond
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serve(ctx, conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Shutdown stops accepting connections and waits for open requests to finish.
+// When ctx is done first, the remaining requests have their contexts cancelled
+// and their connections closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		if s.cancel != nil {
+			s.cancel()
+		}
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	if s.ReadTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+	}
+	tlsConn := conn.(*tls.Conn)
+	if err := tlsConn.Handshake(); err != nil {
+		s.Logger.Debug("gemini handshake failed", "remote", conn.RemoteAddr().String(), "error", err)
+		return
+	}
+
+	// A request is an absolute URL of at most 1024 bytes followed by CRLF.
+	line, err := bufio.NewReaderSize(conn, maxRequestLen+2).ReadSlice('\n')
+	if err != nil {
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return
+		}
+		s.reply(conn, "59 Request too long", nil)
+		return
+	}
+
+	if s.WriteTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.WriteTimeout)
+		defer cancel()
+	}
+	header, body := s.handle(ctx, tlsConn, string(line))
+	if s.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+	}
+	s.reply(conn, header, body)
+}
+
+func (s *Server) handle(ctx context.Context, conn *tls.Conn, line string) (string, []byte) {
+	raw, ok := strings.CutSuffix(line, "\r\n")
+	if !ok || raw == "" {
+		return "59 Bad request", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return "59 Bad request", nil
+	}
+	if u.Scheme != "gemini" || (s.Hostname != "" && !strings.EqualFold(u.Hostname(), s.Hostname)) {
+		return "53 Proxy request refused", nil
+	}
+
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, requestKey{}, true), http.MethodGet, target, nil)
+	if err != nil {
+		return "59 Bad request", nil
+	}
+	req.Host = u.Host
+	req.RemoteAddr = conn.RemoteAddr().String()
+	state := conn.ConnectionState()
+	req.TLS = &state
+
+	rec := &responseBuffer{header: make(http.Header)}
+	if !s.serveHandler(rec, req) {
+		return "40 Internal server error", nil
+	}
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	if rec.status >= 200 && rec.status < 300 {
+		mediaType := rec.header.Get("Content-Type")
+		if mediaType == "" {
+			mediaType = http.DetectContentType(rec.body.Bytes())
+		}
+		return "20 " + geminiMediaType(mediaType), rec.body.Bytes()
+	}
+	return statusLine(rec.status, rec.header, rec.body.Bytes()), nil
+}
+
+// serveHandler runs the handler, recovering from a panic as net/http does so
+// that one bad request doesn't take down the server.
+func (s *Server) serveHandler(w http.ResponseWriter, r *http.Request) (ok bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.Logger.Error("gemini handler panic", "path", r.URL.Path, "panic", err, "stack", string(debug.Stack()))
+			ok = false
+		}
+	}()
+	s.Handler.ServeHTTP(w, r)
+	return true
+}
+
+// statusLine maps an HTTP error or redirect to a Gemini status line, using
+// the first line of the response body as its message.
+func statusLine(status int, header http.Header, body []byte) string {
+	var code string
+	switch {
+	case status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect:
+		return "31 " + header.Get("Location")
+	case status >= 300 && status < 400:
+		return "30 " + header.Get("Location")
+	case status == http.StatusNotFound:
+		code = "51"
+	case status == http.StatusGone:
+		code = "52"
+	case status == http.StatusTooManyRequests:
+		code = "44"
+	case status >= 400 && status < 500:
+		code = "59"
+	case status == http.StatusServiceUnavailable:
+		code = "41"
+	case status == http.StatusBadGateway || status == http.StatusGatewayTimeout:
+		code = "43"
+	default:
+		code = "40"
+	}
+
+	meta, _, _ := strings.Cut(string(body), "\n")
+	meta = strings.TrimSpace(meta)
+	if meta == "" {
+		meta = http.StatusText(status)
+	}
+	if len(meta) > maxMetaLen {
+		meta = meta[:maxMetaLen]
+	}
+	return code + " " + meta
+}
+
+// geminiMediaType drops the charset from UTF-8 responses, which Gemini
+// assumes, and keeps any other parameters.
+func geminiMediaType(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	if strings.EqualFold(params["charset"], "utf-8") {
+		delete(params, "charset")
+	}
+	return mime.FormatMediaType(mediaType, params)
+}
+
+func (s *Server) reply(conn net.Conn, header string, body []byte) {
+	if _, err := conn.Write(append([]byte(header+"\r\n"), body...)); err != nil {
+		s.Logger.Debug("gemini write failed", "remote", conn.RemoteAddr().String(), "error", err)
+	}
+}
+
+// responseBuffer collects a handler's response so its status can be sent
+// before the body.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rb *responseBuffer) Header() http.Header {
+	return rb.header
+}
+
+func (rb *responseBuffer) WriteHeader(status int) {
+	if rb.status == 0 {
+		rb.status = status
+	}
+}
+
+func (rb *responseBuffer) Write(p []byte) (int, error) {
+	if rb.status == 0 {
+		rb.status = http.StatusOK
+	}
+	return rb.body.Write(p)
+}
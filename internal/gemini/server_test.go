@@ -0,0 +1,104 @@
+package gemini
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves handler on a local port and returns its address.
+func startServer(t *testing.T, srv *Server) string {
+	t.Helper()
+	dir := t.TempDir()
+	cert, _, err := LoadOrCreateCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	go srv.Serve(ln)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+	return ln.Addr().String()
+}
+
+// request sends one Gemini request and returns the response header and body.
+func request(t *testing.T, addr, url string) (string, string) {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := io.WriteString(conn, url+"\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, body, _ := strings.Cut(string(resp), "\r\n")
+	return header, body
+}
+
+func TestServer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/gemini; charset=utf-8")
+		if !IsRequest(r.Context()) {
+			http.Error(w, "not marked as a Gemini request", http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, "# "+r.URL.RawQuery+"\n")
+	})
+	mux.HandleFunc("GET /moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	addr := startServer(t, &Server{Handler: mux, Hostname: "localhost"})
+
+	tests := []struct {
+		url    string
+		header string
+		body   string
+	}{
+		{"gemini://localhost/page?q=hi", "20 text/gemini", "# q=hi\n"},
+		{"gemini://LOCALHOST:1965/page?a%20b", "20 text/gemini", "# a%20b\n"},
+		{"gemini://localhost/moved", "30 /page", ""},
+		{"gemini://localhost/missing", "51 404 page not found", ""},
+		{"gemini://localhost/panic", "40 Internal server error", ""},
+		{"gemini://example.com/page", "53 Proxy request refused", ""},
+		{"https://localhost/page", "53 Proxy request refused", ""},
+		{"/page", "59 Bad request", ""},
+		{"gemini://localhost/" + strings.Repeat("a", maxRequestLen), "59 Request too long", ""},
+	}
+
+	for _, tt := range tests {
+		header, body := request(t, addr, tt.url)
+		if header != tt.header || body != tt.body {
+			t.Errorf("%.40s: got %q %q, want %q %q", tt.url, header, body, tt.header, tt.body)
+		}
+	}
+
+	// The server is still up after the panic.
+	if header, _ := request(t, addr, "gemini://localhost/page"); header != "20 text/gemini" {
+		t.Errorf("after panic: got %q", header)
+	}
+}
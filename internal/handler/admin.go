@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"hackernews/internal/cache"
+)
+
+func (a *App) AdminRoutes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /admin/caches", a.cacheStatsHandler)
+	mux.HandleFunc("POST /admin/caches/{name}/purge", a.cachePurgeHandler)
+	mux.HandleFunc("GET /admin/feeds", a.feedStatusHandler)
+	mux.HandleFunc("POST /admin/feeds/{name}/refresh", a.feedRefreshHandler)
+
+	return a.requireAdminToken(mux)
+}
+
+func (a *App) requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := a.config().Admin.Token
+		if token != "" {
+			given, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *App) cacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]any, len(a.Caches))
+	for name, c := range a.Caches {
+		stats[name] = c.Stats()
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (a *App) cachePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	c, ok := a.Caches[name]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown cache"})
+		return
+	}
+
+	key := r.FormValue("key")
+	prefix := r.FormValue("prefix")
+
+	var removed int
+	switch {
+	case key != "":
+		if c.Delete(key) {
+			removed = 1
+		}
+	case prefix != "":
+		removed = c.DeletePrefix(prefix)
+	default:
+		removed = c.Stats().Entries
+		c.Purge()
+	}
+
+	a.Logger.Info("purged cache", "cache", name, "key", key, "prefix", prefix, "removed", removed)
+	writeJSON(w, http.StatusOK, map[string]any{"cache": name, "removed": removed})
+}
+
+func (a *App) feedStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if a.Refresher == nil {
+		writeJSON(w, http.StatusOK, []cache.FeedStatus{})
+		return
+	}
+	writeJSON(w, http.StatusOK, a.Refresher.Status())
+}
+
+func (a *App) feedRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if a.Refresher == nil || !a.Refresher.RefreshNow(name) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown feed"})
+		return
+	}
+
+	a.Logger.Info("scheduled feed refresh", "feed", name)
+	writeJSON(w, http.StatusAccepted, map[string]string{"feed": name})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
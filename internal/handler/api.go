@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"hackernews/internal/hn"
+)
+
+const apiVersion = "v1"
+
+// apiStoryTypes maps the list names accepted by /api/v1/stories/{type} to
+// upstream story types; "polls" is served from the item cache.
+var apiStoryTypes = map[string]string{
+	"top":   "top",
+	"front": "top",
+	"best":  "best",
+	"new":   "new",
+	"ask":   "ask",
+	"show":  "show",
+	"job":   "job",
+	"polls": "polls",
+}
+
+type apiResponse struct {
+	Version    string         `json:"version"`
+	Data       any            `json:"data,omitempty"`
+	Pagination *apiPagination `json:"pagination,omitempty"`
+	Stale      bool           `json:"stale,omitempty"`
+	Error      *apiError      `json:"error,omitempty"`
+}
+
+type apiPagination struct {
+	Page    int  `json:"page"`
+	PerPage int  `json:"per_page"`
+	Total   *int `json:"total,omitempty"`
+	HasMore bool `json:"has_more"`
+}
+
+type apiError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiItem struct {
+	*hn.Item
+	Comments  []*apiItem `json:"comments,omitempty"`
+	Options   []*apiItem `json:"options,omitempty"`
+	Ancestors []*apiItem `json:"ancestors,omitempty"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+type apiUser struct {
+	*hn.User
+	Items []*apiItem `json:"items"`
+}
+
+func newAPIItem(item *hn.Item) *apiItem {
+	return &apiItem{
+		Item:      item,
+		Comments:  newAPIItems(item.Comments),
+		Options:   newAPIItems(item.Options),
+		Truncated: item.Truncated,
+	}
+}
+
+func newAPIItems(items []*hn.Item) []*apiItem {
+	out := make([]*apiItem, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			out = append(out, newAPIItem(item))
+		}
+	}
+	return out
+}
+
+func (a *App) apiRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/stories/{type}", a.apiStoriesHandler)
+	mux.HandleFunc("GET /api/v1/item/{id}", a.apiItemHandler)
+	mux.HandleFunc("GET /api/v1/user/{id}", a.apiUserHandler)
+	mux.HandleFunc("GET /api/", func(w http.ResponseWriter, r *http.Request) {
+		a.apiErrorResponse(w, http.StatusNotFound, "No such endpoint.")
+	})
+}
+
+func (a *App) apiStoriesHandler(w http.ResponseWriter, r *http.Request) {
+	storyType, ok := apiStoryTypes[r.PathValue("type")]
+	if !ok {
+		a.apiErrorResponse(w, http.StatusNotFound, "No such list.")
+		return
+	}
+
+	page, ok := a.apiPage(w, r)
+	if !ok {
+		return
+	}
+
+	var ids []int
+	if storyType == "polls" {
+		for _, poll := range a.HackerNews.CachedItemsOfType("poll") {
+			ids = append(ids, poll.ID)
+		}
+	} else {
+		var err error
+		ids, err = a.HackerNews.GetStoryIDs(r.Context(), storyType)
+		if err != nil {
+			a.Logger.Error("failed to get stories", "type", storyType, "page", page, "error", err)
+			a.apiUpstreamError(w, err, "No such list.")
+			return
+		}
+	}
+
+	perPage := a.config().HackerNewsAPI.ItemsPerPage
+	start, end := hn.PageBounds(page, perPage, len(ids))
+
+	stories, err := a.HackerNews.GetItemsByIDs(r.Context(), ids[start:end])
+	if err != nil {
+		a.Logger.Error("failed to get stories", "type", storyType, "page", page, "error", err)
+		a.apiUpstreamError(w, err, "No such list.")
+		return
+	}
+
+	total := len(ids)
+	a.apiRespond(w, r, newAPIItems(stories), &apiPagination{
+		Page:    page,
+		PerPage: perPage,
+		Total:   &total,
+		HasMore: end < total,
+	})
+}
+
+func (a *App) apiItemHandler(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		a.apiErrorResponse(w, http.StatusBadRequest, "Invalid item ID.")
+		return
+	}
+
+	page, ok := a.apiPage(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := a.HackerNews.GetItemPage(r.Context(), itemID, page)
+	if err != nil {
+		a.Logger.Error("failed to get item", "id", itemID, "error", err)
+		a.apiUpstreamError(w, err, "No such item.")
+		return
+	}
+
+	ancestors, err := a.HackerNews.GetAncestors(r.Context(), item)
+	if err != nil {
+		a.Logger.Error("failed to get item ancestors", "id", itemID, "error", err)
+	}
+
+	data := newAPIItem(item)
+	for _, ancestor := range ancestors {
+		data.Ancestors = append(data.Ancestors, &apiItem{Item: ancestor})
+	}
+
+	var pagination *apiPagination
+	if perPage := a.config().HackerNewsAPI.Comments.PerPage; perPage > 0 {
+		total := len(item.Kids)
+		pagination = &apiPagination{
+			Page:    page,
+			PerPage: perPage,
+			Total:   &total,
+			HasMore: moreComments(item, page, perPage),
+		}
+	}
+	a.apiRespond(w, r, data, pagination)
+}
+
+func (a *App) apiUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+
+	viewType := r.URL.Query().Get("view")
+	if viewType != "" && viewType != "submissions" && viewType != "comments" {
+		a.apiErrorResponse(w, http.StatusBadRequest, "View must be submissions or comments.")
+		return
+	}
+
+	page, ok := a.apiPage(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := a.HackerNews.GetUser(r.Context(), userID)
+	if err != nil {
+		a.Logger.Error("failed to get user", "id", userID, "error", err)
+		a.apiUpstreamError(w, err, "No such user.")
+		return
+	}
+
+	if viewType == "" {
+		a.apiRespond(w, r, user, nil)
+		return
+	}
+
+	perPage := a.config().HackerNewsAPI.ItemsPerPage
+	items := a.userItems(r.Context(), user, viewType, page, perPage)
+	data := &apiUser{User: user, Items: newAPIItems(items)}
+	a.apiRespond(w, r, data, &apiPagination{
+		Page:    page,
+		PerPage: perPage,
+		HasMore: len(items) == perPage,
+	})
+}
+
+func (a *App) apiPage(w http.ResponseWriter, r *http.Request) (int, bool) {
+	pageStr := r.URL.Query().Get("page")
+	if pageStr == "" {
+		return 1, true
+	}
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		a.apiErrorResponse(w, http.StatusBadRequest, "Page must be a positive integer.")
+		return 0, false
+	}
+	return page, true
+}
+
+func (a *App) apiRespond(w http.ResponseWriter, r *http.Request, data any, pagination *apiPagination) {
+	writeJSON(w, http.StatusOK, apiResponse{
+		Version:    apiVersion,
+		Data:       data,
+		Pagination: pagination,
+		Stale:      hn.ServedStale(r.Context()),
+	})
+}
+
+func (a *App) apiUpstreamError(w http.ResponseWriter, err error, notFoundMessage string) {
+	switch {
+	case errors.Is(err, hn.ErrNotFound):
+		a.apiErrorResponse(w, http.StatusNotFound, notFoundMessage)
+	case errors.Is(err, hn.ErrUpstream):
+		a.apiErrorResponse(w, http.StatusBadGateway, "Hacker News could not be reached. Please try again in a moment.")
+	default:
+		a.apiErrorResponse(w, http.StatusInternalServerError, "Something went wrong.")
+	}
+}
+
+func (a *App) apiErrorResponse(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiResponse{
+		Version: apiVersion,
+		Error: &apiError{
+			Status:  status,
+			Code:    apiErrorCodes[status],
+			Message: message,
+		},
+	})
+}
+
+var apiErrorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusNotFound:            "not_found",
+	http.StatusBadGateway:          "upstream_unavailable",
+	http.StatusInternalServerError: "internal_error",
+}
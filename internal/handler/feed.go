@@ -0,0 +1,400 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"hackernews/internal/hn"
+	"hackernews/internal/view"
+)
+
+// feedLists maps each story list to the path of its HTML page; the top list's
+// feeds are served at /rss, /atom and /feed.json.
+var feedLists = map[string]string{
+	"top":   "/",
+	"best":  "/best",
+	"new":   "/new",
+	"ask":   "/ask",
+	"show":  "/show",
+	"job":   "/job",
+	"polls": "/polls",
+}
+
+type feed struct {
+	Title       string
+	Link        string
+	Self        string
+	Description string
+	Entries     []feedEntry
+}
+
+type feedEntry struct {
+	Title     string
+	Link      string
+	Comments  string
+	Author    string
+	AuthorURL string
+	Published time.Time
+	Content   string
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Comments    string  `xml:"comments"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Links     []atomLink `xml:"link"`
+	Author    atomPerson `xml:"author"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	ExternalURL   string           `json:"external_url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHTML   string           `json:"content_html"`
+	DatePublished string           `json:"date_published"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// maxFilterPages bounds how many pages of a list a filtered feed scans for
+// matching stories.
+const maxFilterPages = 5
+
+var feedWriters = map[string]func(http.ResponseWriter, *feed){
+	"rss":  writeRSS,
+	"atom": writeAtom,
+	"json": writeJSONFeed,
+}
+
+func (a *App) feedRoutes(mux *http.ServeMux) {
+	for storyType := range feedLists {
+		for format, write := range feedWriters {
+			mux.HandleFunc("GET "+feedPath(storyType, format), a.storiesFeedHandler(storyType, write))
+		}
+	}
+	mux.HandleFunc("GET /user/{file}", a.userFeedHandler)
+}
+
+func feedPath(storyType, format string) string {
+	if storyType == "top" {
+		if format == "json" {
+			return "/feed.json"
+		}
+		return "/" + format
+	}
+	return "/" + storyType + "." + format
+}
+
+func withQuery(path, query string) string {
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+func (a *App) storiesFeedHandler(storyType string, write func(http.ResponseWriter, *feed)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter := parseStoryFilter(r.URL.Query())
+		stories, err := a.feedStories(r.Context(), storyType, filter)
+		if err != nil {
+			a.Logger.Error("failed to get stories", "type", storyType, "error", err)
+			a.upstreamError(w, r, err, "No such list.")
+			return
+		}
+
+		base := a.siteURL(r)
+		f := &feed{
+			Title:       "Hacker News: " + storyType,
+			Link:        base + withQuery(feedLists[storyType], filter.query()),
+			Self:        base + r.URL.RequestURI(),
+			Description: "Links for the intellectually curious, ranked by readers.",
+		}
+		for _, story := range stories {
+			if story.Deleted || story.Dead {
+				continue
+			}
+			f.Entries = append(f.Entries, itemEntry(base, story))
+		}
+		write(w, f)
+	}
+}
+
+// feedStories returns up to a page of stories from the list that pass the
+// filter, reading further pages while the filter leaves the feed short.
+func (a *App) feedStories(ctx context.Context, storyType string, filter storyFilter) ([]*hn.Item, error) {
+	limit := a.config().HackerNewsAPI.ItemsPerPage
+	if storyType == "polls" {
+		polls := filter.apply(a.HackerNews.CachedItemsOfType("poll"))
+		return polls[:min(len(polls), limit)], nil
+	}
+
+	var matched []*hn.Item
+	for page := 1; page <= maxFilterPages && len(matched) < limit; page++ {
+		stories, err := a.HackerNews.GetStoriesForPage(ctx, storyType, page)
+		if err != nil {
+			if page > 1 {
+				break
+			}
+			return nil, err
+		}
+		for _, story := range stories {
+			if story != nil && filter.match(story) {
+				matched = append(matched, story)
+			}
+		}
+		if len(stories) < limit {
+			break
+		}
+	}
+	return matched[:min(len(matched), limit)], nil
+}
+
+// userFeedHandler serves /user/{id}.rss, .atom and .json with the user's
+// submissions, or their comments when view=comments.
+func (a *App) userFeedHandler(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	dot := strings.LastIndexByte(file, '.')
+	if dot <= 0 {
+		a.notFoundHandler(w, r)
+		return
+	}
+	userID := file[:dot]
+	write, ok := feedWriters[file[dot+1:]]
+	if !ok {
+		a.notFoundHandler(w, r)
+		return
+	}
+
+	viewType := "submissions"
+	if r.URL.Query().Get("view") == "comments" {
+		viewType = "comments"
+	}
+
+	user, err := a.HackerNews.GetUser(r.Context(), userID)
+	if err != nil {
+		a.Logger.Error("failed to get user", "id", userID, "error", err)
+		a.upstreamError(w, r, err, "No such user.")
+		return
+	}
+
+	base := a.siteURL(r)
+	f := &feed{
+		Title:       fmt.Sprintf("Hacker News: %s's %s", user.ID, viewType),
+		Link:        fmt.Sprintf("%s/user?id=%s&view=%s", base, user.ID, viewType),
+		Self:        base + r.URL.RequestURI(),
+		Description: fmt.Sprintf("Recent %s by %s on Hacker News.", viewType, user.ID),
+	}
+	for _, item := range a.userItems(r.Context(), user, viewType, 1, a.config().HackerNewsAPI.ItemsPerPage) {
+		f.Entries = append(f.Entries, itemEntry(base, item))
+	}
+	write(w, f)
+}
+
+func itemEntry(base string, item *hn.Item) feedEntry {
+	comments := fmt.Sprintf("%s/item?id=%d", base, item.ID)
+	entry := feedEntry{
+		Title:     item.Title,
+		Link:      item.URL,
+		Comments:  comments,
+		Author:    item.By,
+		AuthorURL: base + "/user?id=" + item.By,
+		Published: time.Unix(item.Time, 0).UTC(),
+	}
+	if entry.Link == "" {
+		entry.Link = comments
+	}
+
+	if item.Type == "comment" {
+		entry.Title = "Comment by " + item.By
+		entry.Content = string(view.FormatText(item.Text))
+		return entry
+	}
+
+	if item.Text != "" {
+		entry.Content = string(view.FormatText(item.Text))
+	}
+	entry.Content += fmt.Sprintf(`<p>%d points by %s | <a href="%s">%d comments</a></p>`,
+		item.Score, html.EscapeString(item.By), html.EscapeString(comments), item.Descendants)
+	return entry
+}
+
+func (f *feed) updated() time.Time {
+	var latest time.Time
+	for _, entry := range f.Entries {
+		if entry.Published.After(latest) {
+			latest = entry.Published
+		}
+	}
+	if latest.IsZero() {
+		return time.Now().UTC()
+	}
+	return latest
+}
+
+func writeRSS(w http.ResponseWriter, f *feed) {
+	doc := rssDocument{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: f.updated().Format(time.RFC1123Z),
+		},
+	}
+	for _, entry := range f.Entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			Comments:    entry.Comments,
+			Description: entry.Content,
+			GUID:        rssGUID{IsPermaLink: true, Value: entry.Comments},
+			PubDate:     entry.Published.Format(time.RFC1123Z),
+		})
+	}
+	writeXML(w, "application/rss+xml; charset=utf-8", doc)
+}
+
+func writeAtom(w http.ResponseWriter, f *feed) {
+	doc := atomDocument{
+		Title:   f.Title,
+		ID:      f.Self,
+		Updated: f.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+		},
+	}
+	for _, entry := range f.Entries {
+		published := entry.Published.Format(time.RFC3339)
+		doc.Entries = append(doc.Entries, atomEntry{
+			Title:     entry.Title,
+			ID:        entry.Comments,
+			Published: published,
+			Updated:   published,
+			Links: []atomLink{
+				{Rel: "alternate", Href: entry.Link},
+				{Rel: "replies", Type: "text/html", Href: entry.Comments},
+			},
+			Author:  atomPerson{Name: entry.Author, URI: entry.AuthorURL},
+			Content: atomText{Type: "html", Body: entry.Content},
+		})
+	}
+	writeXML(w, "application/atom+xml; charset=utf-8", doc)
+}
+
+func writeJSONFeed(w http.ResponseWriter, f *feed) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.Self,
+		Description: f.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, entry := range f.Entries {
+		item := jsonFeedItem{
+			ID:            entry.Comments,
+			URL:           entry.Comments,
+			Title:         entry.Title,
+			ContentHTML:   entry.Content,
+			DatePublished: entry.Published.Format(time.RFC3339),
+			Authors:       []jsonFeedAuthor{{Name: entry.Author, URL: entry.AuthorURL}},
+		}
+		if entry.Link != entry.Comments {
+			item.ExternalURL = entry.Link
+		}
+		doc.Items = append(doc.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	json.NewEncoder(w).Encode(doc)
+}
+
+func writeXML(w http.ResponseWriter, contentType string, v any) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(xml.Header)+len(body)))
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"hackernews/internal/hn"
+)
+
+// storyFilter narrows story lists by score, comment count and domain. It is
+// read from the points, comments and domain query parameters by both the HTML
+// lists and the feeds.
+type storyFilter struct {
+	MinPoints   int
+	MinComments int
+	Domain      string
+}
+
+func parseStoryFilter(q url.Values) storyFilter {
+	var f storyFilter
+	if n, err := strconv.Atoi(q.Get("points")); err == nil && n > 0 {
+		f.MinPoints = n
+	}
+	if n, err := strconv.Atoi(q.Get("comments")); err == nil && n > 0 {
+		f.MinComments = n
+	}
+	f.Domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(q.Get("domain"))), "www.")
+	return f
+}
+
+func (f storyFilter) active() bool {
+	return f.MinPoints > 0 || f.MinComments > 0 || f.Domain != ""
+}
+
+func (f storyFilter) match(item *hn.Item) bool {
+	if item == nil || item.Score < f.MinPoints || item.Descendants < f.MinComments {
+		return false
+	}
+	if f.Domain != "" {
+		host := strings.ToLower(item.Host())
+		return host == f.Domain || strings.HasSuffix(host, "."+f.Domain)
+	}
+	return true
+}
+
+func (f storyFilter) apply(items []*hn.Item) []*hn.Item {
+	if !f.active() {
+		return items
+	}
+	matched := make([]*hn.Item, 0, len(items))
+	for _, item := range items {
+		if f.match(item) {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
+
+// query encodes the filter for appending to pagination and feed links.
+func (f storyFilter) query() string {
+	q := url.Values{}
+	if f.MinPoints > 0 {
+		q.Set("points", strconv.Itoa(f.MinPoints))
+	}
+	if f.MinComments > 0 {
+		q.Set("comments", strconv.Itoa(f.MinComments))
+	}
+	if f.Domain != "" {
+		q.Set("domain", f.Domain)
+	}
+	return q.Encode()
+}
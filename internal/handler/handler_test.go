@@ -0,0 +1,122 @@
+package handler_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"hackernews/internal/handler"
+	"hackernews/internal/hn"
+	"hackernews/internal/hn/hntest"
+	"hackernews/internal/view"
+)
+
+// newTestApp returns an App backed by a fake upstream serving fixtures, with
+// the templates and static files the server embeds.
+func newTestApp(t *testing.T, fixtures string) (*handler.App, *hntest.Server) {
+	t.Helper()
+	f, err := hntest.LoadFixtures(strings.NewReader(fixtures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := hntest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	templates, err := view.NewTemplateCache(os.DirFS("../../cmd/server/web/template"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := srv.Config()
+	cfg.HackerNewsAPI.Retry.MaxAttempts = 1
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := hn.NewClient(logger, cfg, hn.NewHTTPBackend(&cfg.HackerNewsAPI))
+	t.Cleanup(client.Close)
+
+	return &handler.App{
+		Logger:        logger,
+		Config:        cfg,
+		HackerNews:    client,
+		Caches:        client.Caches(),
+		TemplateCache: templates,
+		StaticFS:      os.DirFS("../../cmd/server/web/static"),
+	}, srv
+}
+
+// get serves one request, with header given as name and value pairs, and
+// returns the response.
+func get(h http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		if header[i] == "Host" {
+			r.Host = header[i+1]
+		} else {
+			r.Header.Set(header[i], header[i+1])
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestPublicAdminFollowsTokenReload(t *testing.T) {
+	app, _ := newTestApp(t, `{}`)
+	routes := app.Routes()
+
+	steps := []struct {
+		token  string
+		auth   string
+		status int
+	}{
+		{"", "", http.StatusNotFound},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "Bearer secret", http.StatusOK},
+		{"", "Bearer secret", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		cfg := *app.Config
+		cfg.Admin.Token = step.token
+		app.Reload(&cfg)
+
+		if w := get(routes, "/admin/caches", "Authorization", step.auth); w.Code != step.status {
+			t.Errorf("token %q, auth %q: status %d, want %d", step.token, step.auth, w.Code, step.status)
+		}
+	}
+}
+
+const storyFixtures = `{
+ "items": {
+  "1": {"id":1,"type":"story","by":"alice","title":"Hello","url":"https://example.com/a","score":10,"time":1700000000,"descendants":1,"kids":[2]},
+  "2": {"id":2,"type":"comment","by":"bob","parent":1,"text":"First","time":1700000100}
+ },
+ "users": {"alice": {"id":"alice","created":1600000000,"karma":10,"submitted":[1]}},
+ "stories": {"top":[1],"new":[1],"best":[1],"ask":[],"show":[],"job":[]}
+}`
+
+func TestAbsoluteLinksUsePublicURL(t *testing.T) {
+	app, _ := newTestApp(t, storyFixtures)
+	routes := app.Routes()
+	poisoned := []string{"Host", "evil.example", "X-Forwarded-Proto", "https"}
+
+	for _, target := range []string{"/rss", "/atom", "/feed.json", "/user/alice.rss", "/?format=text"} {
+		if body := get(routes, target, poisoned...).Body.String(); !strings.Contains(body, "https://evil.example/") {
+			t.Errorf("%s without a public URL: want links from the request, got\n%s", target, body)
+		}
+	}
+
+	cfg := *app.Config
+	cfg.Server.PublicURL = "https://news.example.com/"
+	app.Reload(&cfg)
+
+	for _, target := range []string{"/rss", "/atom", "/feed.json", "/user/alice.rss", "/?format=text"} {
+		body := get(routes, target, poisoned...).Body.String()
+		if strings.Contains(body, "evil.example") || !strings.Contains(body, "https://news.example.com/item?id=1") {
+			t.Errorf("%s with a public URL: want links to news.example.com only, got\n%s", target, body)
+		}
+	}
+}
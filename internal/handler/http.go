@@ -1,12 +1,18 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"html/template"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"hackernews/internal/cache"
 	"hackernews/internal/config"
 	"hackernews/internal/hn"
 	"hackernews/internal/view"
@@ -15,26 +21,95 @@ import (
 type App struct {
 	Logger        *slog.Logger
 	Config        *config.Config
-	HackerNews    *hn.Client
+	reloaded      atomic.Pointer[config.Config]
+	HackerNews    hn.Source
+	Caches        map[string]cache.Managed
+	Refresher     *cache.Refresher
 	TemplateCache map[string]*template.Template
 	StaticFS      fs.FS
 }
 
-func (a *App) Routes() *http.ServeMux {
+// Reload replaces the configuration used by handlers for subsequent requests.
+func (a *App) Reload(cfg *config.Config) {
+	a.reloaded.Store(cfg)
+}
+
+func (a *App) config() *config.Config {
+	if cfg := a.reloaded.Load(); cfg != nil {
+		return cfg
+	}
+	return a.Config
+}
+
+func (a *App) Routes() http.Handler {
 	mux := http.NewServeMux()
 
 	fileServer := http.FileServer(http.FS(a.StaticFS))
 	mux.Handle("GET /static/", http.StripPrefix("/static/", fileServer))
 
+	a.pageRoutes(mux)
+	mux.HandleFunc("GET /healthz", a.healthHandler)
+	a.apiRoutes(mux)
+	a.feedRoutes(mux)
+
+	// Without a separate admin listener the admin routes live here, hidden
+	// while no token is set. The token is checked per request, so one set or
+	// cleared on reload takes effect at once.
+	if a.config().Admin.Addr == "" {
+		admin := a.publicAdmin(a.AdminRoutes())
+		mux.Handle("GET /admin/", admin)
+		mux.Handle("POST /admin/", admin)
+	}
+
+	return a.trackFreshness(mux)
+}
+
+// GeminiRoutes serves only the story list, item and user pages, which are
+// the ones with a gemtext rendering.
+func (a *App) GeminiRoutes() http.Handler {
+	mux := http.NewServeMux()
+	a.pageRoutes(mux)
+	return a.trackFreshness(mux)
+}
+
+func (a *App) pageRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /front", a.storiesHandler("top"))
+	mux.HandleFunc("GET /best", a.storiesHandler("best"))
 	mux.HandleFunc("GET /new", a.storiesHandler("new"))
 	mux.HandleFunc("GET /ask", a.storiesHandler("ask"))
 	mux.HandleFunc("GET /show", a.storiesHandler("show"))
 	mux.HandleFunc("GET /job", a.storiesHandler("job"))
+	mux.HandleFunc("GET /polls", a.pollsHandler)
 	mux.HandleFunc("GET /item", a.itemHandler)
 	mux.HandleFunc("GET /user", a.userHandler)
 	mux.HandleFunc("GET /", a.catchAllHandler)
+}
+
+// publicAdmin hides admin routes on the public port while no token is set,
+// which a reload can cause, rather than serving them unauthenticated.
+func (a *App) publicAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.config().Admin.Token == "" {
+			a.notFoundHandler(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-	return mux
+// siteURL is the base of absolute links: the configured public URL, or the
+// one the request was made to when none is set.
+func (a *App) siteURL(r *http.Request) string {
+	if base := a.config().Server.PublicURL; base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return view.SiteURL(r)
+}
+
+func (a *App) trackFreshness(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(hn.WithFreshness(r.Context())))
+	})
 }
 
 func (a *App) catchAllHandler(w http.ResponseWriter, r *http.Request) {
@@ -51,6 +126,83 @@ func (a *App) notFoundHandler(w http.ResponseWriter, _ *http.Request) {
 	w.Write([]byte("Unknown."))
 }
 
+// healthHandler reports unhealthy once any feed has gone three intervals
+// without a successful refresh.
+func (a *App) healthHandler(w http.ResponseWriter, r *http.Request) {
+	var feeds []cache.FeedStatus
+	if a.Refresher != nil {
+		feeds = a.Refresher.Status()
+	}
+
+	status := http.StatusOK
+	for _, feed := range feeds {
+		if time.Since(feed.LastSuccess) > 3*feed.Interval {
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	writeJSON(w, status, map[string]any{
+		"status": http.StatusText(status),
+		"feeds":  feeds,
+	})
+}
+
+// render writes data as the named page, as gemtext for the Gemini server, in
+// plain text when the client asked for it and as HTML otherwise.
+func (a *App) render(w http.ResponseWriter, r *http.Request, page string, data *view.TemplateData) {
+	w.Header().Add("Vary", "Accept, User-Agent")
+	data.SiteURL = a.siteURL(r)
+	if view.WantsGemtext(r) {
+		view.RenderGemtext(w, r, page, data)
+		return
+	}
+	if view.WantsText(r) {
+		view.RenderText(w, r, page, data)
+		return
+	}
+
+	name := page + ".page.tmpl"
+	tmpl, ok := a.TemplateCache[name]
+	if !ok {
+		a.Logger.Error("template not found: " + name)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	view.Render(w, r, tmpl, data)
+}
+
+func (a *App) upstreamError(w http.ResponseWriter, r *http.Request, err error, notFoundMessage string) {
+	switch {
+	case errors.Is(err, hn.ErrNotFound):
+		a.errorPage(w, r, http.StatusNotFound, notFoundMessage)
+	case errors.Is(err, hn.ErrUpstream):
+		a.errorPage(w, r, http.StatusBadGateway, "Hacker News could not be reached. Please try again in a moment.")
+	default:
+		a.errorPage(w, r, http.StatusInternalServerError, "Something went wrong.")
+	}
+}
+
+func (a *App) errorPage(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if view.WantsGemtext(r) || view.WantsText(r) {
+		http.Error(w, message, status)
+		return
+	}
+
+	tmpl, ok := a.TemplateCache["error.page.tmpl"]
+	if !ok {
+		a.Logger.Error("template not found: error.page.tmpl")
+		http.Error(w, message, status)
+		return
+	}
+
+	data := &view.TemplateData{
+		StatusText:   http.StatusText(status),
+		ErrorMessage: message,
+	}
+	view.RenderStatus(w, r, tmpl, status, data)
+}
+
 func (a *App) userHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("id")
 	if userID == "" {
@@ -69,74 +221,75 @@ func (a *App) userHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := a.HackerNews.GetUser(r.Context(), userID)
 	if err != nil {
 		a.Logger.Error("failed to get user", "id", userID, "error", err)
-		http.Error(w, "User not found", http.StatusNotFound)
+		a.upstreamError(w, r, err, "No such user.")
 		return
 	}
 
 	data := &view.TemplateData{
 		User:           user,
 		ActiveUserView: viewType,
+		FeedPath:       "/user/" + user.ID + ".rss",
 		CurrentPage:    page,
 		NextPage:       page + 1,
-		ItemsPerPage:   a.Config.HackerNewsAPI.ItemsPerPage,
+		ItemsPerPage:   a.config().HackerNewsAPI.ItemsPerPage,
+	}
+
+	foundItems := a.userItems(r.Context(), user, viewType, page, data.ItemsPerPage)
+	if viewType == "submissions" {
+		data.Submissions = foundItems
+	} else {
+		data.Comments = foundItems
+	}
+
+	a.render(w, r, "user", data)
+}
+
+// userItems returns one page of the user's live submissions or comments,
+// fetching their submitted items in chunks until the page is full.
+func (a *App) userItems(ctx context.Context, user *hn.User, viewType string, page, perPage int) []*hn.Item {
+	if viewType != "submissions" && viewType != "comments" {
+		return nil
 	}
 
 	const chunkSize = 60
-	itemsToSkip := (page - 1) * data.ItemsPerPage
+	itemsToSkip, _ := hn.PageBounds(page, perPage, len(user.Submitted))
 	var skippedCount int
 	var foundItems []*hn.Item
 
-	if (viewType == "submissions" || viewType == "comments") && len(user.Submitted) > 0 {
-		for i := 0; i < len(user.Submitted); i += chunkSize {
-			end := min(i+chunkSize, len(user.Submitted))
-			chunkIDs := user.Submitted[i:end]
+	for i := 0; i < len(user.Submitted); i += chunkSize {
+		end := min(i+chunkSize, len(user.Submitted))
+		chunkIDs := user.Submitted[i:end]
 
-			items, err := a.HackerNews.GetItemsByIDs(r.Context(), chunkIDs)
-			if err != nil {
-				a.Logger.Error("failed to get chunk of user items", "id", userID, "error", err)
-				break
-			}
+		items, err := a.HackerNews.GetItemsByIDs(ctx, chunkIDs)
+		if err != nil {
+			a.Logger.Error("failed to get chunk of user items", "id", user.ID, "error", err)
+			break
+		}
 
-			for _, item := range items {
-				if item == nil || item.Deleted || item.Dead {
-					continue
-				}
+		for _, item := range items {
+			if item == nil || item.Deleted || item.Dead {
+				continue
+			}
 
-				isComment := item.Type == "comment"
-				isCorrectType := (viewType == "submissions" && !isComment) || (viewType == "comments" && isComment)
+			isComment := item.Type == "comment"
+			isCorrectType := (viewType == "submissions" && !isComment) || (viewType == "comments" && isComment)
 
-				if !isCorrectType {
-					continue
-				}
+			if !isCorrectType {
+				continue
+			}
 
-				if skippedCount < itemsToSkip {
-					skippedCount++
-					continue
-				}
+			if skippedCount < itemsToSkip {
+				skippedCount++
+				continue
+			}
 
-				foundItems = append(foundItems, item)
-				if len(foundItems) >= data.ItemsPerPage {
-					goto doneFiltering
-				}
+			foundItems = append(foundItems, item)
+			if len(foundItems) >= perPage {
+				return foundItems
 			}
 		}
 	}
-
-doneFiltering:
-	if viewType == "submissions" {
-		data.Submissions = foundItems
-	} else {
-		data.Comments = foundItems
-	}
-
-	tmpl, ok := a.TemplateCache["user.page.tmpl"]
-	if !ok {
-		a.Logger.Error("template not found: user.page.tmpl")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	view.Render(w, r, tmpl, data)
+	return foundItems
 }
 
 func (a *App) storiesHandler(storyType string) http.HandlerFunc {
@@ -150,26 +303,60 @@ func (a *App) storiesHandler(storyType string) http.HandlerFunc {
 		stories, err := a.HackerNews.GetStoriesForPage(r.Context(), storyType, page)
 		if err != nil {
 			a.Logger.Error("failed to get stories", "type", storyType, "page", page, "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-
-		tmpl, ok := a.TemplateCache["index.page.tmpl"]
-		if !ok {
-			a.Logger.Error("template not found: index.page.tmpl")
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			a.upstreamError(w, r, err, "No such list.")
 			return
 		}
+		filter := parseStoryFilter(r.URL.Query())
+		perPage := a.config().HackerNewsAPI.ItemsPerPage
 
 		data := &view.TemplateData{
-			Stories:      stories,
+			Stories:      filter.apply(stories),
 			ActiveNav:    storyType,
+			PagePath:     r.URL.Path,
+			FeedPath:     withQuery(feedPath(storyType, "rss"), filter.query()),
+			FilterQuery:  template.URL(filter.query()),
+			MoreStories:  len(stories) == perPage,
 			CurrentPage:  page,
 			NextPage:     page + 1,
-			ItemsPerPage: a.Config.HackerNewsAPI.ItemsPerPage,
+			ItemsPerPage: perPage,
 		}
-		view.Render(w, r, tmpl, data)
+		a.render(w, r, "index", data)
+	}
+}
+
+func (a *App) pollsHandler(w http.ResponseWriter, r *http.Request) {
+	pageStr := r.URL.Query().Get("page")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
 	}
+
+	filter := parseStoryFilter(r.URL.Query())
+	perPage := a.config().HackerNewsAPI.ItemsPerPage
+	polls := filter.apply(a.HackerNews.CachedItemsOfType("poll"))
+	start, end := hn.PageBounds(page, perPage, len(polls))
+
+	data := &view.TemplateData{
+		Stories:      polls[start:end],
+		ActiveNav:    "polls",
+		PagePath:     r.URL.Path,
+		FeedPath:     withQuery(feedPath("polls", "rss"), filter.query()),
+		FilterQuery:  template.URL(filter.query()),
+		MoreStories:  end < len(polls),
+		CurrentPage:  page,
+		NextPage:     page + 1,
+		ItemsPerPage: perPage,
+	}
+	a.render(w, r, "index", data)
+}
+
+// moreComments reports whether item has top-level comments after page.
+func moreComments(item *hn.Item, page, perPage int) bool {
+	if perPage <= 0 {
+		return false
+	}
+	_, end := hn.PageBounds(page, perPage, len(item.Kids))
+	return end < len(item.Kids)
 }
 
 func (a *App) itemHandler(w http.ResponseWriter, r *http.Request) {
@@ -180,23 +367,32 @@ func (a *App) itemHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	item, err := a.HackerNews.GetItem(r.Context(), itemID)
+	pageStr := r.URL.Query().Get("page")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	item, err := a.HackerNews.GetItemPage(r.Context(), itemID, page)
 	if err != nil {
 		a.Logger.Error("failed to get item", "id", itemID, "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		a.upstreamError(w, r, err, "No such item.")
 		return
 	}
 
-	tmpl, ok := a.TemplateCache["item.page.tmpl"]
-	if !ok {
-		a.Logger.Error("template not found: item.page.tmpl")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	ancestors, err := a.HackerNews.GetAncestors(r.Context(), item)
+	if err != nil {
+		a.Logger.Error("failed to get item ancestors", "id", itemID, "error", err)
 	}
 
+	perPage := a.config().HackerNewsAPI.Comments.PerPage
 	data := &view.TemplateData{
-		Item:      item,
-		ActiveNav: "",
+		Item:         item,
+		Ancestors:    ancestors,
+		ActiveNav:    "",
+		CurrentPage:  page,
+		NextPage:     page + 1,
+		MoreComments: moreComments(item, page, perPage),
 	}
-	view.Render(w, r, tmpl, data)
+	a.render(w, r, "item", data)
 }
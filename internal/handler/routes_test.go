@@ -0,0 +1,77 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		target string
+		fail   string // upstream path answering 503
+		status int
+		want   string
+	}{
+		{target: "/", status: http.StatusOK, want: `<title>Hacker News | top</title>`},
+		{target: "/new", status: http.StatusOK, want: `Hello`},
+		{target: "/polls", status: http.StatusOK, want: `<title>Hacker News | polls</title>`},
+		{target: "/item?id=1", status: http.StatusOK, want: `First`},
+		{target: "/item?id=999", status: http.StatusNotFound, want: `Not Found`},
+		{target: "/item?id=abc", status: http.StatusBadRequest, want: `Invalid item ID`},
+		{target: "/user?id=alice", status: http.StatusOK, want: `<title>Hacker News | alice</title>`},
+		{target: "/user?id=nobody", status: http.StatusNotFound, want: `Not Found`},
+		{target: "/user", status: http.StatusBadRequest, want: `Missing user ID`},
+		{target: "/nope", status: http.StatusNotFound, want: `Unknown.`},
+		{target: "/healthz", status: http.StatusOK, want: `"status":"OK"`},
+
+		{target: "/api/v1/stories/top", status: http.StatusOK, want: `"title":"Hello"`},
+		{target: "/api/v1/stories/nope", status: http.StatusNotFound, want: `"code":"not_found"`},
+		{target: "/api/v1/item/1", status: http.StatusOK, want: `"text":"First"`},
+		{target: "/api/v1/item/999", status: http.StatusNotFound, want: `"code":"not_found"`},
+		{target: "/api/v1/user/alice", status: http.StatusOK, want: `"karma":10`},
+		{target: "/api/v1/user/nobody", status: http.StatusNotFound, want: `"code":"not_found"`},
+		{target: "/api/v1/nope", status: http.StatusNotFound, want: `No such endpoint.`},
+
+		{target: "/rss", status: http.StatusOK, want: `<link>https://example.com/a</link>`},
+		{target: "/atom", status: http.StatusOK, want: `<feed xmlns="http://www.w3.org/2005/Atom">`},
+		{target: "/feed.json", status: http.StatusOK, want: `"external_url":"https://example.com/a"`},
+		{target: "/best.rss", status: http.StatusOK, want: `<title>Hacker News: best</title>`},
+		{target: "/user/alice.rss", status: http.StatusOK, want: `<title>Hello</title>`},
+		{target: "/user/alice.atom", status: http.StatusOK, want: `alice&#39;s submissions`},
+		{target: "/user/alice.json", status: http.StatusOK, want: `"title":"Hello"`},
+		{target: "/user/nobody.rss", status: http.StatusNotFound, want: `Not Found`},
+
+		{target: "/", fail: "/topstories.json", status: http.StatusBadGateway, want: `Bad Gateway`},
+		{target: "/item?id=1", fail: "/item/1.json", status: http.StatusBadGateway, want: `Bad Gateway`},
+		{target: "/user?id=alice", fail: "/user/alice.json", status: http.StatusBadGateway, want: `Bad Gateway`},
+		{target: "/api/v1/stories/top", fail: "/topstories.json", status: http.StatusBadGateway, want: `"code":"upstream_unavailable"`},
+		{target: "/api/v1/item/1", fail: "/item/1.json", status: http.StatusBadGateway, want: `"code":"upstream_unavailable"`},
+		{target: "/api/v1/user/alice", fail: "/user/alice.json", status: http.StatusBadGateway, want: `"code":"upstream_unavailable"`},
+		{target: "/new.rss", fail: "/newstories.json", status: http.StatusBadGateway, want: `Bad Gateway`},
+		{target: "/user/alice.atom", fail: "/user/alice.json", status: http.StatusBadGateway, want: `Bad Gateway`},
+	}
+
+	for _, tt := range tests {
+		name := tt.target
+		if tt.fail != "" {
+			name += " failing " + tt.fail
+		}
+		t.Run(name, func(t *testing.T) {
+			// Each case gets its own app so nothing is served from a cache
+			// filled by an earlier case.
+			app, srv := newTestApp(t, storyFixtures)
+			if tt.fail != "" {
+				srv.Fail(tt.fail, http.StatusServiceUnavailable)
+			}
+
+			w := get(app.Routes(), tt.target)
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+			if body := w.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("body does not contain %q:\n%s", tt.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package hn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"hackernews/internal/config"
+)
+
+type Backend interface {
+	FetchItem(ctx context.Context, id int) (*Item, error)
+	FetchUser(ctx context.Context, id string) (*User, error)
+	FetchStoryIDs(ctx context.Context, storyType string) ([]int, error)
+	FetchUpdates(ctx context.Context) (*Updates, error)
+}
+
+type Updates struct {
+	Items    []int    `json:"items"`
+	Profiles []string `json:"profiles"`
+}
+
+type HTTPBackend struct {
+	httpClient *http.Client
+	baseURL    string
+	retry      atomic.Pointer[retryPolicy]
+}
+
+func NewHTTPBackend(cfg *config.HackerNewsAPIConfig) *HTTPBackend {
+	b := &HTTPBackend{
+		httpClient: &http.Client{},
+		baseURL:    cfg.BaseURL,
+	}
+	b.Reload(cfg)
+	return b
+}
+
+func (b *HTTPBackend) Reload(cfg *config.HackerNewsAPIConfig) {
+	b.retry.Store(&retryPolicy{cfg: cfg.Retry})
+}
+
+func (b *HTTPBackend) FetchItem(ctx context.Context, id int) (*Item, error) {
+	var item Item
+	if err := b.getJSON(ctx, fmt.Sprintf("/item/%d.json", id), &item); err != nil {
+		return nil, fmt.Errorf("item %d: %w", id, err)
+	}
+	return &item, nil
+}
+
+func (b *HTTPBackend) FetchUser(ctx context.Context, id string) (*User, error) {
+	var user User
+	if err := b.getJSON(ctx, fmt.Sprintf("/user/%s.json", id), &user); err != nil {
+		return nil, fmt.Errorf("user %s: %w", id, err)
+	}
+	return &user, nil
+}
+
+func (b *HTTPBackend) FetchStoryIDs(ctx context.Context, storyType string) ([]int, error) {
+	var ids []int
+	if err := b.getJSON(ctx, fmt.Sprintf("/%sstories.json", storyType), &ids); err != nil {
+		return nil, fmt.Errorf("%s story IDs: %w", storyType, err)
+	}
+	return ids, nil
+}
+
+func (b *HTTPBackend) FetchUpdates(ctx context.Context) (*Updates, error) {
+	var updates Updates
+	if err := b.getJSON(ctx, "/updates.json", &updates); err != nil {
+		return nil, fmt.Errorf("updates: %w", err)
+	}
+	return &updates, nil
+}
+
+func (b *HTTPBackend) getJSON(ctx context.Context, path string, v any) error {
+	return b.retry.Load().do(ctx, func() error {
+		return b.fetchJSON(ctx, path, v)
+	})
+}
+
+func (b *HTTPBackend) fetchJSON(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch: %w", &transportError{err: err})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", &transportError{err: err})
+	}
+
+	// Firebase answers unknown items and users with 200 and a literal null.
+	if bytes.Equal(bytes.TrimSpace(body), []byte("null")) {
+		return ErrNotFound
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: failed to decode: %w", ErrUpstream, err)
+	}
+	return nil
+}
@@ -1,60 +1,66 @@
 package hn
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"hackernews/internal/cache"
 	"hackernews/internal/config"
 )
 
+type Source interface {
+	GetItem(ctx context.Context, id int) (*Item, error)
+	GetItemPage(ctx context.Context, id int, page int) (*Item, error)
+	GetAncestors(ctx context.Context, item *Item) ([]*Item, error)
+	GetUser(ctx context.Context, id string) (*User, error)
+	GetItemsByIDs(ctx context.Context, ids []int) ([]*Item, error)
+	GetStoryIDs(ctx context.Context, storyType string) ([]int, error)
+	GetStoriesForPage(ctx context.Context, storyType string, page int) ([]*Item, error)
+	CachedItemsOfType(itemType string) []*Item
+}
+
 type Client struct {
-	httpClient  *http.Client
+	backend     Backend
 	itemCache   *cache.Cache[*Item]
 	userCache   *cache.Cache[*User]
 	idListCache *cache.Cache[[]int]
+	itemFlight  flightGroup[*Item]
+	userFlight  flightGroup[*User]
+	idFlight    flightGroup[[]int]
 	logger      *slog.Logger
-	cfg         *config.HackerNewsAPIConfig
+	cfg         atomic.Pointer[config.HackerNewsAPIConfig]
 }
 
 func (c *Client) GetUser(ctx context.Context, id string) (*User, error) {
 	cacheKey := fmt.Sprintf("user:%s", id)
-	if cached, found := c.userCache.Get(cacheKey); found {
-		return cached, nil
-	}
-
-	url := fmt.Sprintf("%s/user/%s.json", c.cfg.BaseURL, id)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request for user %s: %w", id, err)
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch user %s: %w", id, err)
-	}
-	defer resp.Body.Close()
-
-	var user User
-	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return nil, fmt.Errorf("failed to decode user %s: %w", id, err)
-	}
+	return cachedFetch(ctx, c.logger, c.userCache, &c.userFlight, cacheKey, func(ctx context.Context) (*User, error) {
+		user, err := c.backend.FetchUser(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch user: %w", err)
+		}
 
-	c.userCache.Set(cacheKey, &user)
-	return &user, nil
+		c.userCache.Set(cacheKey, user)
+		return user, nil
+	})
 }
 
 func (c *Client) GetItemsByIDs(ctx context.Context, ids []int) ([]*Item, error) {
+	return c.fetchItems(ctx, ids, c.api().WorkerCount), nil
+}
+
+func (c *Client) fetchItems(ctx context.Context, ids []int, workers int) []*Item {
 	items := make([]*Item, len(ids))
 	jobs := make(chan int, len(ids))
 	results := make(chan *Item, len(ids))
 
 	var wg sync.WaitGroup
-	for i := 0; i < c.cfg.WorkerCount; i++ {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go c.storyWorker(ctx, &wg, jobs, results)
 	}
@@ -78,7 +84,7 @@ func (c *Client) GetItemsByIDs(ctx context.Context, ids []int) ([]*Item, error)
 		items[i] = idToItemMap[id]
 	}
 
-	return items, nil
+	return items
 }
 
 func (c *Client) GetStoriesForPage(ctx context.Context, storyType string, page int) ([]*Item, error) {
@@ -87,28 +93,78 @@ func (c *Client) GetStoriesForPage(ctx context.Context, storyType string, page i
 		return nil, err
 	}
 
-	start := (page - 1) * c.cfg.ItemsPerPage
-	end := start + c.cfg.ItemsPerPage
-
-	if start >= len(allStoryIDs) {
+	start, end := PageBounds(page, c.api().ItemsPerPage, len(allStoryIDs))
+	if start == end {
 		return []*Item{}, nil
 	}
-	if end > len(allStoryIDs) {
-		end = len(allStoryIDs)
-	}
 
 	return c.GetItemsByIDs(ctx, allStoryIDs[start:end])
 }
 
-func NewClient(logger *slog.Logger, cfg *config.Config) *Client {
-	return &Client{
-		httpClient:  &http.Client{},
-		itemCache:   cache.New[*Item](cfg.Cache.ItemTTL * 2),
-		userCache:   cache.New[*User](cfg.Cache.ItemTTL * 2),
-		idListCache: cache.New[[]int](cfg.Cache.ItemTTL),
-		logger:      logger,
-		cfg:         &cfg.HackerNewsAPI,
+// PageBounds returns the slice bounds of the 1-based page of perPage entries
+// out of total. Pages past the end are empty, however large the page number.
+func PageBounds(page, perPage, total int) (start, end int) {
+	if perPage <= 0 {
+		return 0, total
+	}
+	page = max(page, 1)
+	if page-1 > total/perPage {
+		return total, total
+	}
+	start = (page - 1) * perPage
+	return start, min(start+perPage, total)
+}
+
+func NewClient(logger *slog.Logger, cfg *config.Config, backend Backend) *Client {
+	c := &Client{
+		backend: backend,
+		itemCache: cache.New[*Item](cfg.Cache.ItemTTL*2,
+			cache.WithMaxEntries(cfg.Cache.MaxItems),
+			cache.WithMaxBytes(cfg.Cache.MaxItemBytes),
+			cache.WithJanitor(cfg.Cache.JanitorInterval),
+			cache.WithStaleWhileRevalidate(cfg.Cache.StaleWhileRevalidate),
+			cache.WithStaleIfError(cfg.Cache.StaleIfError),
+		),
+		userCache: cache.New[*User](cfg.Cache.ItemTTL*2,
+			cache.WithMaxEntries(cfg.Cache.MaxUsers),
+			cache.WithJanitor(cfg.Cache.JanitorInterval),
+			cache.WithStaleWhileRevalidate(cfg.Cache.StaleWhileRevalidate),
+			cache.WithStaleIfError(cfg.Cache.StaleIfError),
+		),
+		idListCache: cache.New[[]int](cfg.Cache.ItemTTL,
+			cache.WithJanitor(cfg.Cache.JanitorInterval),
+			cache.WithStaleWhileRevalidate(cfg.Cache.StaleWhileRevalidate),
+			cache.WithStaleIfError(cfg.Cache.StaleIfError),
+		),
+		logger: logger,
 	}
+	c.Reload(cfg)
+	return c
+}
+
+// Reload swaps in the API settings that are safe to change at runtime. Cache
+// sizes and lifetimes are fixed at construction.
+func (c *Client) Reload(cfg *config.Config) {
+	api := cfg.HackerNewsAPI
+	c.cfg.Store(&api)
+}
+
+func (c *Client) api() *config.HackerNewsAPIConfig {
+	return c.cfg.Load()
+}
+
+func (c *Client) Caches() map[string]cache.Managed {
+	return map[string]cache.Managed{
+		"items":   c.itemCache,
+		"users":   c.userCache,
+		"idlists": c.idListCache,
+	}
+}
+
+func (c *Client) Close() {
+	c.itemCache.Close()
+	c.userCache.Close()
+	c.idListCache.Close()
 }
 
 func (c *Client) GetItem(ctx context.Context, id int) (*Item, error) {
@@ -117,74 +173,131 @@ func (c *Client) GetItem(ctx context.Context, id int) (*Item, error) {
 		return nil, err
 	}
 
-	if len(item.Kids) > 0 {
-		item.Comments = c.fetchComments(ctx, item.Kids)
+	root := *item
+	c.loadPollOptions(ctx, &root)
+	c.loadComments(ctx, &root, root.Kids)
+	return &root, nil
+}
+
+func (c *Client) GetItemPage(ctx context.Context, id int, page int) (*Item, error) {
+	item, err := c.fetchItem(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	return item, nil
+	root := *item
+	kids := root.Kids
+	if perPage := c.api().Comments.PerPage; perPage > 0 {
+		start, end := PageBounds(page, perPage, len(kids))
+		kids = kids[start:end]
+	}
+
+	c.loadPollOptions(ctx, &root)
+	c.loadComments(ctx, &root, kids)
+	return &root, nil
 }
 
-func (c *Client) GetStoryIDs(ctx context.Context, storyType string) ([]int, error) {
-	cacheKey := fmt.Sprintf("idlist:%s", storyType)
-	if cached, found := c.idListCache.Get(cacheKey); found {
-		c.logger.Info("serving story ID list from cache", "type", storyType)
-		return cached, nil
+func (c *Client) CachedItemsOfType(itemType string) []*Item {
+	var items []*Item
+	c.itemCache.Range(func(_ string, item *Item) bool {
+		if item.Type == itemType {
+			items = append(items, item)
+		}
+		return true
+	})
+
+	slices.SortFunc(items, func(a, b *Item) int {
+		return cmp.Compare(b.Time, a.Time)
+	})
+	return items
+}
+
+func (c *Client) loadPollOptions(ctx context.Context, poll *Item) {
+	if poll.Type != "poll" || len(poll.Parts) == 0 {
+		return
 	}
 
-	c.logger.Info("fetching story ID list from API", "type", storyType)
-	url := fmt.Sprintf("%s/%sstories.json", c.cfg.BaseURL, storyType)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request for story IDs: %w", err)
+	options := c.fetchItems(ctx, poll.Parts, c.api().WorkerCount)
+	poll.Options = slices.DeleteFunc(options, func(option *Item) bool {
+		return option == nil || option.Deleted || option.Dead
+	})
+}
+
+func (c *Client) GetAncestors(ctx context.Context, item *Item) ([]*Item, error) {
+	const maxHops = 100
+
+	var ancestors []*Item
+	for parentID := item.Parent; parentID != 0 && len(ancestors) < maxHops; {
+		parent, err := c.fetchItem(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, parent)
+		parentID = parent.Parent
 	}
 
-	resp, err := c.httpClient.Do(req)
+	slices.Reverse(ancestors)
+	return ancestors, nil
+}
+
+func (c *Client) GetStoryIDs(ctx context.Context, storyType string) ([]int, error) {
+	cacheKey := fmt.Sprintf("idlist:%s", storyType)
+	return cachedFetch(ctx, c.logger, c.idListCache, &c.idFlight, cacheKey, func(ctx context.Context) ([]int, error) {
+		return c.fetchStoryIDsUpstream(ctx, storyType)
+	})
+}
+
+func (c *Client) RefreshStoryIDs(ctx context.Context, storyType string) ([]int, error) {
+	cacheKey := fmt.Sprintf("idlist:%s", storyType)
+	return c.idFlight.Do(ctx, cacheKey, func(ctx context.Context) ([]int, error) {
+		return c.fetchStoryIDsUpstream(ctx, storyType)
+	})
+}
+
+func (c *Client) fetchStoryIDsUpstream(ctx context.Context, storyType string) ([]int, error) {
+	c.logger.Info("fetching story ID list from API", "type", storyType)
+	ids, err := c.backend.FetchStoryIDs(ctx, storyType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch story IDs: %w", err)
 	}
-	defer resp.Body.Close()
-
-	var ids []int
-	if err := json.NewDecoder(resp.Body).Decode(&ids); err != nil {
-		return nil, fmt.Errorf("failed to decode story IDs: %w", err)
-	}
 
-	c.idListCache.Set(cacheKey, ids)
+	c.idListCache.Set(fmt.Sprintf("idlist:%s", storyType), ids)
 	return ids, nil
 }
 
 func (c *Client) fetchItem(ctx context.Context, id int) (*Item, error) {
-	if cachedItem, found := c.itemCache.Get(fmt.Sprintf("item:%d", id)); found {
-		return cachedItem, nil
-	}
+	cacheKey := fmt.Sprintf("item:%d", id)
+	return cachedFetch(ctx, c.logger, c.itemCache, &c.itemFlight, cacheKey, func(ctx context.Context) (*Item, error) {
+		return c.fetchItemUpstream(ctx, id)
+	})
+}
 
-	url := fmt.Sprintf("%s/item/%d.json", c.cfg.BaseURL, id)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request for item %d: %w", id, err)
-	}
+func (c *Client) fetchItemUpstream(ctx context.Context, id int) (*Item, error) {
+	cacheKey := fmt.Sprintf("item:%d", id)
 
-	resp, err := c.httpClient.Do(req)
+	item, err := c.backend.FetchItem(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch item %d: %w", id, err)
-	}
-	defer resp.Body.Close()
-
-	var item Item
-	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
-		return nil, fmt.Errorf("failed to decode item %d: %w", id, err)
+		if errors.Is(err, ErrNotFound) {
+			c.itemCache.Delete(cacheKey)
+		}
+		return nil, fmt.Errorf("failed to fetch item: %w", err)
 	}
 
-	if !item.Deleted && !item.Dead {
-		c.itemCache.Set(fmt.Sprintf("item:%d", id), &item)
+	if item.Deleted || item.Dead {
+		c.itemCache.Delete(cacheKey)
+	} else {
+		c.itemCache.Set(cacheKey, item)
 	}
 
-	return &item, nil
+	return item, nil
 }
 
 func (c *Client) storyWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan int, results chan<- *Item) {
 	defer wg.Done()
 	for id := range jobs {
+		if ctx.Err() != nil {
+			continue
+		}
 		item, err := c.fetchItem(ctx, id)
 		if err != nil {
 			c.logger.Error("failed to fetch story item", "id", id, "error", err)
@@ -194,17 +307,80 @@ func (c *Client) storyWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-cha
 	}
 }
 
-func (c *Client) fetchComments(ctx context.Context, ids []int) []*Item {
-	comments := make([]*Item, 0, len(ids))
-	for _, id := range ids {
-		comment, err := c.GetItem(ctx, id)
-		if err != nil {
-			c.logger.Error("failed to fetch comment", "id", id, "error", err)
-			continue
+type commentLevel struct {
+	parent *Item
+	kids   []int
+}
+
+// loadComments fetches the comment tree under root breadth-first, one level
+// per GetItemsByIDs batch. Subtrees cut off by the depth, size or time limits
+// are marked Truncated on their parent. Cached items are shared, so every
+// comment attached to the tree is a copy.
+func (c *Client) loadComments(ctx context.Context, root *Item, kids []int) {
+	limits := c.api().Comments
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	loaded := 0
+	level := []commentLevel{{parent: root, kids: kids}}
+
+	for depth := 1; len(level) > 0; depth++ {
+		if (limits.MaxDepth > 0 && depth > limits.MaxDepth) || ctx.Err() != nil {
+			for _, l := range level {
+				l.parent.Truncated = true
+			}
+			return
+		}
+
+		var ids []int
+		batch := make([]commentLevel, 0, len(level))
+		for _, l := range level {
+			kids := l.kids
+			if limits.MaxComments > 0 {
+				budget := limits.MaxComments - loaded - len(ids)
+				if budget < len(kids) {
+					kids = kids[:max(budget, 0)]
+					l.parent.Truncated = true
+				}
+			}
+			if len(kids) == 0 {
+				continue
+			}
+			ids = append(ids, kids...)
+			batch = append(batch, commentLevel{parent: l.parent, kids: kids})
 		}
-		if comment != nil && !comment.Deleted && !comment.Dead {
-			comments = append(comments, comment)
+		loaded += len(ids)
+
+		items, _ := c.GetItemsByIDs(ctx, ids)
+
+		var next []commentLevel
+		i := 0
+		for _, l := range batch {
+			l.parent.Comments = make([]*Item, 0, len(l.kids))
+			for range l.kids {
+				item := items[i]
+				i++
+
+				if item == nil {
+					if ctx.Err() != nil {
+						l.parent.Truncated = true
+					}
+					continue
+				}
+				if item.Deleted || item.Dead {
+					continue
+				}
+
+				comment := *item
+				l.parent.Comments = append(l.parent.Comments, &comment)
+				if len(comment.Kids) > 0 {
+					next = append(next, commentLevel{parent: &comment, kids: comment.Kids})
+				}
+			}
 		}
+		level = next
 	}
-	return comments
 }
@@ -0,0 +1,145 @@
+package hn_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hackernews/internal/hn"
+	"hackernews/internal/hn/hntest"
+)
+
+const fixtures = `{
+ "items": {"1": {"id":1,"type":"story","by":"alice","title":"Hello","time":1700000000}},
+ "users": {"alice": {"id":"alice","created":1600000000,"karma":10}},
+ "stories": {"top": [1]}
+}`
+
+// gatedBackend holds every fetch until release is closed, so concurrent
+// callers are all in flight before the first upstream request completes.
+type gatedBackend struct {
+	hn.Backend
+	release chan struct{}
+}
+
+func (b *gatedBackend) FetchItem(ctx context.Context, id int) (*hn.Item, error) {
+	<-b.release
+	return b.Backend.FetchItem(ctx, id)
+}
+
+func (b *gatedBackend) FetchUser(ctx context.Context, id string) (*hn.User, error) {
+	<-b.release
+	return b.Backend.FetchUser(ctx, id)
+}
+
+func (b *gatedBackend) FetchStoryIDs(ctx context.Context, storyType string) ([]int, error) {
+	<-b.release
+	return b.Backend.FetchStoryIDs(ctx, storyType)
+}
+
+func TestClientCollapsesConcurrentFetches(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		fetch func(ctx context.Context, c *hn.Client) (bool, error)
+	}{
+		{
+			name: "GetItemsByIDs",
+			path: "/item/1.json",
+			fetch: func(ctx context.Context, c *hn.Client) (bool, error) {
+				items, err := c.GetItemsByIDs(ctx, []int{1})
+				return len(items) == 1 && items[0] != nil, err
+			},
+		},
+		{
+			name: "GetUser",
+			path: "/user/alice.json",
+			fetch: func(ctx context.Context, c *hn.Client) (bool, error) {
+				user, err := c.GetUser(ctx, "alice")
+				return user != nil && user.ID == "alice", err
+			},
+		},
+		{
+			name: "GetStoryIDs",
+			path: "/topstories.json",
+			fetch: func(ctx context.Context, c *hn.Client) (bool, error) {
+				ids, err := c.GetStoryIDs(ctx, "top")
+				return len(ids) == 1, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := hntest.LoadFixtures(strings.NewReader(fixtures))
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := hntest.NewServer(f)
+			defer srv.Close()
+
+			cfg := srv.Config()
+			backend := &gatedBackend{Backend: hn.NewHTTPBackend(&cfg.HackerNewsAPI), release: make(chan struct{})}
+			client := hn.NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, backend)
+			defer client.Close()
+
+			const n = 50
+			var started, done sync.WaitGroup
+			ok := make([]bool, n)
+			errs := make([]error, n)
+			for i := range n {
+				started.Add(1)
+				done.Add(1)
+				go func() {
+					defer done.Done()
+					started.Done()
+					ok[i], errs[i] = tt.fetch(t.Context(), client)
+				}()
+			}
+			started.Wait()
+			time.Sleep(50 * time.Millisecond)
+			close(backend.release)
+			done.Wait()
+
+			for i := range n {
+				if errs[i] != nil || !ok[i] {
+					t.Errorf("caller %d: ok=%v err=%v", i, ok[i], errs[i])
+				}
+			}
+			if hits := srv.Hits(tt.path); hits != 1 {
+				t.Errorf("%d upstream requests to %s, want 1", hits, tt.path)
+			}
+		})
+	}
+}
+
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		page, perPage, total int
+		start, end           int
+	}{
+		{1, 10, 25, 0, 10},
+		{3, 10, 25, 20, 25},
+		{4, 10, 25, 25, 25},
+		{0, 10, 25, 0, 10},
+		{-5, 10, 25, 0, 10},
+		{2, 10, 20, 10, 20},
+		{3, 10, 20, 20, 20},
+		{1, 10, 0, 0, 0},
+		{1, 0, 25, 0, 25},
+		{184467440737095519, 30, 25, 25, 25},
+		{math.MaxInt, 50, 100, 100, 100},
+	}
+
+	for _, tt := range tests {
+		start, end := hn.PageBounds(tt.page, tt.perPage, tt.total)
+		if start != tt.start || end != tt.end {
+			t.Errorf("PageBounds(%d, %d, %d) = %d, %d, want %d, %d", tt.page, tt.perPage, tt.total, start, end, tt.start, tt.end)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package hn
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrUpstream = errors.New("upstream error")
+)
+
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected upstream status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *StatusError) Is(target error) bool {
+	if target == ErrNotFound {
+		return e.StatusCode == http.StatusNotFound
+	}
+	return target == ErrUpstream
+}
+
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Is(target error) bool {
+	return target == ErrUpstream
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,141 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until n callers are waiting on key.
+func waitForWaiters[T any](t *testing.T, g *flightGroup[T], key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		g.mu.Lock()
+		c := g.calls[key]
+		waiting := c != nil && c.waiters == n
+		g.mu.Unlock()
+		if waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d callers on %q", n, key)
+}
+
+func TestFlightGroupCollapsesConcurrentCalls(t *testing.T) {
+	var g flightGroup[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	const n = 20
+	results := make([]int, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = g.Do(t.Context(), "key", func(context.Context) (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+		}()
+	}
+
+	waitForWaiters(t, &g, "key", n)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn called %d times, want 1", got)
+	}
+	for i := range n {
+		if errs[i] != nil || results[i] != 42 {
+			t.Errorf("caller %d got (%d, %v), want (42, nil)", i, results[i], errs[i])
+		}
+	}
+}
+
+func TestFlightGroupOutlivesCancelledCaller(t *testing.T) {
+	var g flightGroup[int]
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		select {
+		case <-release:
+			return 42, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	leaving, leave := context.WithCancel(t.Context())
+	leftErr := make(chan error, 1)
+	go func() {
+		_, err := g.Do(leaving, "key", fn)
+		leftErr <- err
+	}()
+	waitForWaiters(t, &g, "key", 1)
+
+	stayed := make(chan int, 1)
+	go func() {
+		v, _ := g.Do(t.Context(), "key", fn)
+		stayed <- v
+	}()
+	waitForWaiters(t, &g, "key", 2)
+
+	leave()
+	if err := <-leftErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+	waitForWaiters(t, &g, "key", 1)
+
+	close(release)
+	if v := <-stayed; v != 42 {
+		t.Errorf("remaining caller got %d, want 42", v)
+	}
+}
+
+func TestFlightGroupCancelsWhenAllCallersLeave(t *testing.T) {
+	var g flightGroup[int]
+	fnCancelled := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(fnCancelled)
+		return 0, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := g.Do(ctx, "key", fn)
+			errs <- err
+		}()
+	}
+	waitForWaiters(t, &g, "key", 2)
+
+	cancel()
+	for range 2 {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("caller got %v, want context.Canceled", err)
+		}
+	}
+
+	select {
+	case <-fnCancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shared call was not cancelled after every caller left")
+	}
+
+	g.mu.Lock()
+	_, found := g.calls["key"]
+	g.mu.Unlock()
+	if found {
+		t.Error("abandoned call still registered")
+	}
+}
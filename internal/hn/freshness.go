@@ -0,0 +1,59 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"hackernews/internal/cache"
+)
+
+const backgroundRefreshTimeout = 30 * time.Second
+
+type freshnessKey struct{}
+
+func WithFreshness(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshnessKey{}, new(atomic.Bool))
+}
+
+func ServedStale(ctx context.Context) bool {
+	stale, ok := ctx.Value(freshnessKey{}).(*atomic.Bool)
+	return ok && stale.Load()
+}
+
+func markStale(ctx context.Context) {
+	if stale, ok := ctx.Value(freshnessKey{}).(*atomic.Bool); ok {
+		stale.Store(true)
+	}
+}
+
+// cachedFetch serves fresh entries directly, serves stale entries while a
+// single background refresh runs, and falls back to expired entries when the
+// upstream fetch fails. fetch is responsible for populating the cache.
+func cachedFetch[T any](ctx context.Context, logger *slog.Logger, store *cache.Cache[T], flight *flightGroup[T], key string, fetch func(context.Context) (T, error)) (T, error) {
+	cached, state := store.GetStale(key)
+	switch state {
+	case cache.Fresh:
+		return cached, nil
+	case cache.Stale:
+		markStale(ctx)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backgroundRefreshTimeout)
+			defer cancel()
+			if _, err := flight.Do(ctx, key, fetch); err != nil {
+				logger.Warn("background cache refresh failed", "key", key, "error", err)
+			}
+		}()
+		return cached, nil
+	}
+
+	value, err := flight.Do(ctx, key, fetch)
+	if err != nil && state == cache.Expired && ctx.Err() == nil && !errors.Is(err, ErrNotFound) {
+		logger.Warn("serving expired cache entry after upstream error", "key", key, "error", err)
+		markStale(ctx)
+		return cached, nil
+	}
+	return value, err
+}
@@ -0,0 +1,208 @@
+package hntest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"hackernews/internal/config"
+	"hackernews/internal/hn"
+)
+
+type Fixtures struct {
+	Items   map[string]json.RawMessage `json:"items"`
+	Users   map[string]json.RawMessage `json:"users"`
+	Stories map[string][]int           `json:"stories"`
+}
+
+func LoadFixtures(r io.Reader) (*Fixtures, error) {
+	var f Fixtures
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to decode fixtures: %w", err)
+	}
+	return &f, nil
+}
+
+func LoadFixturesFile(path string) (*Fixtures, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixtures: %w", err)
+	}
+	defer file.Close()
+	return LoadFixtures(file)
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu      sync.RWMutex
+	items   map[int]json.RawMessage
+	users   map[string]json.RawMessage
+	stories map[string][]int
+	updates hn.Updates
+	hits    map[string]int
+	fails   map[string]int
+	streams map[string]map[*subscriber]struct{}
+}
+
+func NewServer(f *Fixtures) *Server {
+	s := &Server{
+		items:   make(map[int]json.RawMessage),
+		users:   make(map[string]json.RawMessage),
+		stories: make(map[string][]int),
+		hits:    make(map[string]int),
+		fails:   make(map[string]int),
+		streams: make(map[string]map[*subscriber]struct{}),
+	}
+
+	if f != nil {
+		for key, raw := range f.Items {
+			id, err := strconv.Atoi(key)
+			if err != nil {
+				continue
+			}
+			s.items[id] = raw
+		}
+		for id, raw := range f.Users {
+			s.users[id] = raw
+		}
+		for storyType, ids := range f.Stories {
+			s.stories[storyType] = ids
+		}
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) Config() *config.Config {
+	cfg := config.New()
+	cfg.HackerNewsAPI.BaseURL = s.URL
+	return cfg
+}
+
+func (s *Server) SetItem(item *hn.Item) {
+	raw, _ := json.Marshal(item)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[item.ID] = raw
+}
+
+func (s *Server) SetUser(user *hn.User) {
+	raw, _ := json.Marshal(user)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.ID] = raw
+}
+
+func (s *Server) SetStories(storyType string, ids []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stories[storyType] = ids
+}
+
+func (s *Server) SetUpdates(updates hn.Updates) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updates = updates
+}
+
+func (s *Server) Hits(path string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hits[path]
+}
+
+// Fail makes requests for path, such as "/item/1.json", answer with status.
+// A status of 0 serves the path normally again.
+func (s *Server) Fail(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == 0 {
+		delete(s.fails, path)
+	} else {
+		s.fails[path] = status
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.hits[r.URL.Path]++
+	status := s.fails[r.URL.Path]
+	s.mu.Unlock()
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	path, ok := strings.CutSuffix(r.URL.Path, ".json")
+	if !ok || r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	raw, known := s.lookup(path)
+	if !known {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Header.Get("Accept") == "text/event-stream" {
+		s.serveStream(w, r, path, raw)
+		return
+	}
+	writeRaw(w, raw)
+}
+
+func (s *Server) lookup(path string) (json.RawMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch {
+	case path == "/updates":
+		raw, _ := json.Marshal(s.updates)
+		return raw, true
+	case path == "/maxitem":
+		maxID := 0
+		for id := range s.items {
+			maxID = max(maxID, id)
+		}
+		raw, _ := json.Marshal(maxID)
+		return raw, true
+	case strings.HasPrefix(path, "/item/"):
+		id, err := strconv.Atoi(strings.TrimPrefix(path, "/item/"))
+		if err != nil {
+			return nil, true
+		}
+		return s.items[id], true
+	case strings.HasPrefix(path, "/user/"):
+		return s.users[strings.TrimPrefix(path, "/user/")], true
+	case strings.HasSuffix(path, "stories"):
+		storyType := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "stories")
+		ids, found := s.stories[storyType]
+		if !found {
+			return nil, true
+		}
+		raw, _ := json.Marshal(ids)
+		return raw, true
+	default:
+		return nil, false
+	}
+}
+
+// writeRaw mirrors Firebase, which answers unknown paths with 200 and a literal null.
+func writeRaw(w http.ResponseWriter, raw json.RawMessage) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if raw == nil {
+		w.Write([]byte("null"))
+		return
+	}
+	w.Write(raw)
+}
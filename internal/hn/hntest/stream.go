@@ -0,0 +1,109 @@
+package hntest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+type streamEvent struct {
+	eventType string
+	data      []byte
+}
+
+type subscriber struct {
+	events    chan streamEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (sub *subscriber) close() {
+	sub.closeOnce.Do(func() {
+		close(sub.done)
+	})
+}
+
+// Publish sends a Firebase-style event to every client streaming path, e.g.
+// Publish("/topstories", "put", "/", ids). It does not change the stored data.
+func (s *Server) Publish(path, eventType, subPath string, data any) error {
+	payload, err := json.Marshal(map[string]any{"path": subPath, "data": data})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	s.mu.RLock()
+	subscribers := make([]*subscriber, 0, len(s.streams[path]))
+	for sub := range s.streams[path] {
+		subscribers = append(subscribers, sub)
+	}
+	s.mu.RUnlock()
+
+	for _, sub := range subscribers {
+		select {
+		case sub.events <- streamEvent{eventType: eventType, data: payload}:
+		case <-sub.done:
+		}
+	}
+	return nil
+}
+
+// CloseStreams drops every open stream, as Firebase does when it cancels one.
+func (s *Server) CloseStreams() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path, subscribers := range s.streams {
+		for sub := range subscribers {
+			sub.close()
+		}
+		delete(s.streams, path)
+	}
+}
+
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, path string, initial json.RawMessage) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := &subscriber{
+		events: make(chan streamEvent, 16),
+		done:   make(chan struct{}),
+	}
+	s.mu.Lock()
+	if s.streams[path] == nil {
+		s.streams[path] = make(map[*subscriber]struct{})
+	}
+	s.streams[path][sub] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		sub.close()
+		s.mu.Lock()
+		delete(s.streams[path], sub)
+		s.mu.Unlock()
+	}()
+
+	if initial == nil {
+		initial = json.RawMessage("null")
+	}
+	payload, _ := json.Marshal(map[string]json.RawMessage{"path": json.RawMessage(`"/"`), "data": initial})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "event: put\ndata: %s\n\n", payload)
+	flusher.Flush()
+
+	for {
+		select {
+		case ev := <-sub.events:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.eventType, ev.data)
+			flusher.Flush()
+		case <-sub.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
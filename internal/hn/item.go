@@ -21,7 +21,24 @@ type Item struct {
 	Score       int     `json:"score"`
 	Title       string  `json:"title"`
 	Descendants int     `json:"descendants"`
+	Parts       []int   `json:"parts"`
+	Poll        int     `json:"poll"`
 	Comments    []*Item `json:"-"`
+	Options     []*Item `json:"-"`
+	Truncated   bool    `json:"-"`
+}
+
+func (item *Item) Size() int {
+	const overhead = 160
+	return overhead + len(item.Type) + len(item.By) + len(item.Text) + len(item.URL) + len(item.Title) + 8*(len(item.Kids)+len(item.Parts))
+}
+
+func (item *Item) PollVotes() int {
+	total := 0
+	for _, option := range item.Options {
+		total += option.Score
+	}
+	return total
 }
 
 func (item *Item) Host() string {
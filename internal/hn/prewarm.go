@@ -0,0 +1,40 @@
+package hn
+
+import (
+	"context"
+
+	"hackernews/internal/config"
+)
+
+// Prewarm loads the items on the first pages of a story list, and optionally
+// their top-level comments, into the cache. It runs at most opts.Workers
+// fetches at a time so it leaves room for user traffic.
+func (c *Client) Prewarm(ctx context.Context, storyType string, opts config.PrewarmConfig) (int, error) {
+	ids, err := c.GetStoryIDs(ctx, storyType)
+	if err != nil {
+		return 0, err
+	}
+
+	ids = ids[:min(opts.Pages*c.api().ItemsPerPage, len(ids))]
+	workers := max(opts.Workers, 1)
+
+	warmed := 0
+	var kids []int
+	for _, item := range c.fetchItems(ctx, ids, workers) {
+		if item == nil {
+			continue
+		}
+		warmed++
+		if opts.Comments {
+			kids = append(kids, item.Kids...)
+		}
+	}
+
+	for _, comment := range c.fetchItems(ctx, kids, workers) {
+		if comment != nil {
+			warmed++
+		}
+	}
+
+	return warmed, nil
+}
@@ -0,0 +1,67 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"hackernews/internal/config"
+)
+
+type retryPolicy struct {
+	cfg config.RetryConfig
+}
+
+func (p retryPolicy) do(ctx context.Context, fn func() error) error {
+	attempts := max(p.cfg.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= attempts || !p.retryable(ctx, err) {
+			return err
+		}
+
+		wait := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (p retryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(p.cfg.RetryableStatusCodes, statusErr.StatusCode)
+	}
+
+	var transportErr *transportError
+	return errors.As(err, &transportErr)
+}
+
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.cfg.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.cfg.MaxDelay > 0 && delay > p.cfg.MaxDelay) {
+		delay = p.cfg.MaxDelay
+	}
+
+	if p.cfg.Jitter > 0 && delay > 0 {
+		spread := float64(delay) * min(p.cfg.Jitter, 1)
+		delay = time.Duration(float64(delay) - spread + rand.Float64()*spread)
+	}
+
+	return delay
+}
@@ -0,0 +1,149 @@
+package hn
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"hackernews/internal/config"
+)
+
+type Event struct {
+	Type string
+	Path string
+	Data json.RawMessage
+}
+
+type Stream struct {
+	httpClient *http.Client
+	baseURL    string
+	retry      retryPolicy
+	logger     *slog.Logger
+}
+
+func NewStream(cfg *config.HackerNewsAPIConfig, logger *slog.Logger) *Stream {
+	return &Stream{
+		httpClient: &http.Client{},
+		baseURL:    cfg.BaseURL,
+		retry:      retryPolicy{cfg: cfg.Retry},
+		logger:     logger,
+	}
+}
+
+// Subscribe streams put and patch events for path until ctx is done,
+// reconnecting with backoff whenever the connection drops.
+func (s *Stream) Subscribe(ctx context.Context, path string, handle func(Event)) error {
+	for attempt := 1; ; attempt++ {
+		received, err := s.connect(ctx, path, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			attempt = 1
+		}
+
+		wait := s.retry.backoff(attempt)
+		s.logger.Warn("stream disconnected, reconnecting", "path", path, "error", err, "wait", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Stream) connect(ctx context.Context, path string, handle func(Event)) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+path+".json", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", &transportError{err: err})
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	received := false
+	err = readEvents(resp.Body, func(ev Event) {
+		received = true
+		handle(ev)
+	})
+	return received, err
+}
+
+var errStreamCancelled = errors.New("stream cancelled by upstream")
+
+// readEvents parses a Firebase event stream. Each event carries a JSON
+// envelope of the form {"path": "/...", "data": ...}.
+func readEvents(r io.Reader, handle func(Event)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var eventType string
+	var data strings.Builder
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if eventType != "" {
+				if err := dispatchEvent(eventType, data.String(), handle); err != nil {
+					return err
+				}
+			}
+			eventType = ""
+			data.Reset()
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return io.ErrUnexpectedEOF
+}
+
+func dispatchEvent(eventType, data string, handle func(Event)) error {
+	switch eventType {
+	case "put", "patch":
+		var envelope struct {
+			Path string          `json:"path"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(data), &envelope); err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", eventType, err)
+		}
+		handle(Event{Type: eventType, Path: envelope.Path, Data: envelope.Data})
+	case "cancel", "auth_revoked":
+		return errStreamCancelled
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package hn_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"hackernews/internal/cache"
+	"hackernews/internal/hn"
+	"hackernews/internal/hn/hntest"
+)
+
+const streamFixtures = `{
+ "items": {
+  "1": {"id":1,"type":"story","by":"alice","title":"One","score":1,"time":1700000000},
+  "2": {"id":2,"type":"story","by":"bob","title":"Two","score":2,"time":1700000000},
+  "3": {"id":3,"type":"story","by":"carol","title":"Three","score":3,"time":1700000000}
+ },
+ "stories": {"top": [1, 2, 3]}
+}`
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatchStoryIDs(t *testing.T) {
+	f, err := hntest.LoadFixtures(strings.NewReader(streamFixtures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := hntest.NewServer(f)
+	defer srv.Close()
+
+	cfg := srv.Config()
+	cfg.HackerNewsAPI.Retry.BaseDelay = 10 * time.Millisecond
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := hn.NewClient(logger, cfg, hn.NewHTTPBackend(&cfg.HackerNewsAPI))
+	defer client.Close()
+
+	caches := client.Caches()
+	idLists := caches["idlists"].(*cache.Cache[[]int])
+	items := caches["items"].(*cache.Cache[*hn.Item])
+
+	ids := func(want ...int) func() bool {
+		return func() bool {
+			got, _ := idLists.Peek("idlist:top")
+			return slices.Equal(got, want)
+		}
+	}
+	item := func(id int, cond func(*hn.Item, bool) bool) func() bool {
+		return func() bool {
+			return cond(items.Peek("item:" + strconv.Itoa(id)))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- client.WatchStoryIDs(ctx, hn.NewStream(&cfg.HackerNewsAPI, logger), "top", hn.WithItemStreams(2))
+	}()
+
+	// The initial put carries the stored list, and the first two stories get
+	// item streams of their own.
+	eventually(t, "initial list", ids(1, 2, 3))
+	eventually(t, "item 1 streamed", item(1, func(it *hn.Item, ok bool) bool { return ok && it.Title == "One" }))
+	eventually(t, "item 2 streamed", item(2, func(it *hn.Item, ok bool) bool { return ok && it.Title == "Two" }))
+	if _, ok := items.Peek("item:3"); ok {
+		t.Error("item 3 is past the item stream limit but was cached")
+	}
+
+	if err := srv.Publish("/item/1", "patch", "/", map[string]any{"score": 99}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "item 1 patched", item(1, func(it *hn.Item, ok bool) bool { return ok && it.Score == 99 && it.Title == "One" }))
+	if err := srv.Publish("/item/2", "put", "/", nil); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "item 2 removed", item(2, func(_ *hn.Item, ok bool) bool { return !ok }))
+
+	// A list change starts streams for stories that moved into the limit.
+	if err := srv.Publish("/topstories", "put", "/", []int{3}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "item 3 streamed", item(3, func(it *hn.Item, ok bool) bool { return ok && it.Title == "Three" }))
+
+	steps := []struct {
+		eventType, path string
+		data            any
+		want            []int
+	}{
+		{"put", "/", []int{3, 1, 2}, []int{3, 1, 2}},
+		{"put", "/1", 7, []int{3, 7, 2}},
+		{"patch", "/", map[string]any{"0": 5, "2": nil}, []int{5, 7}},
+		{"put", "/0", nil, []int{7}},
+	}
+	for _, step := range steps {
+		if err := srv.Publish("/topstories", step.eventType, step.path, step.data); err != nil {
+			t.Fatal(err)
+		}
+		eventually(t, step.eventType+" "+step.path, ids(step.want...))
+	}
+
+	// After the server drops the stream, the client reconnects and receives
+	// the stored list again, then keeps following changes.
+	srv.CloseStreams()
+	eventually(t, "list after reconnect", ids(1, 2, 3))
+	if err := srv.Publish("/topstories", "put", "/", []int{2}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "put after reconnect", ids(2))
+
+	// An index that would leave a gap is refused, and the list is refetched.
+	if err := srv.Publish("/topstories", "put", "/123456789", 5); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "list refetched after bad index", ids(1, 2, 3))
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("WatchStoryIDs returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchStoryIDs did not return after cancel")
+	}
+}
+
+func TestWatchItem(t *testing.T) {
+	f, err := hntest.LoadFixtures(strings.NewReader(`{
+ "items": {"5": {"id":5,"type":"story","by":"alice","title":"Five","score":1,"time":1700000000,"kids":[10,11,12]}}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := hntest.NewServer(f)
+	defer srv.Close()
+
+	cfg := srv.Config()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := hn.NewClient(logger, cfg, hn.NewHTTPBackend(&cfg.HackerNewsAPI))
+	defer client.Close()
+
+	items := client.Caches()["items"].(*cache.Cache[*hn.Item])
+	cached := func(cond func(*hn.Item) bool) func() bool {
+		return func() bool {
+			it, ok := items.Peek("item:5")
+			return ok && cond(it)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go client.WatchItem(ctx, hn.NewStream(&cfg.HackerNewsAPI, logger), 5)
+
+	eventually(t, "initial item", cached(func(it *hn.Item) bool { return it.Title == "Five" }))
+	before, _ := items.Peek("item:5")
+
+	// A field put replaces the cached item rather than writing into the one
+	// readers may hold.
+	if err := srv.Publish("/item/5", "put", "/kids", []int{99, 98}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "kids put", cached(func(it *hn.Item) bool { return slices.Equal(it.Kids, []int{99, 98}) }))
+	if !slices.Equal(before.Kids, []int{10, 11, 12}) {
+		t.Errorf("previously cached item's kids changed to %v", before.Kids)
+	}
+
+	if err := srv.Publish("/item/5", "patch", "/", map[string]any{"score": 7, "title": nil}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "root patch", cached(func(it *hn.Item) bool {
+		return it.Score == 7 && it.Title == "" && slices.Equal(it.Kids, []int{99, 98})
+	}))
+
+	// Changes below a field are applied by refetching the item.
+	srv.SetItem(&hn.Item{ID: 5, Type: "story", By: "alice", Title: "Refetched", Kids: []int{99, 98, 97}})
+	if err := srv.Publish("/item/5", "put", "/kids/2", 97); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "nested put refetch", cached(func(it *hn.Item) bool {
+		return it.Title == "Refetched" && slices.Equal(it.Kids, []int{99, 98, 97})
+	}))
+}
@@ -0,0 +1,73 @@
+package hn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ApplyUpdates pulls the list of recently changed items and profiles and
+// brings the matching cache entries up to date. Items already in the cache are
+// re-fetched so they keep serving fresh scores; changed profiles are evicted.
+// Entries that are not cached are left alone.
+func (c *Client) ApplyUpdates(ctx context.Context) (int, error) {
+	updates, err := c.backend.FetchUpdates(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch updates: %w", err)
+	}
+
+	evicted := 0
+	for _, id := range updates.Profiles {
+		if c.userCache.Delete(fmt.Sprintf("user:%s", id)) {
+			evicted++
+		}
+	}
+
+	var stale []int
+	for _, id := range updates.Items {
+		if c.itemCache.Has(fmt.Sprintf("item:%d", id)) {
+			stale = append(stale, id)
+		}
+	}
+
+	return evicted + c.refreshItems(ctx, stale), nil
+}
+
+func (c *Client) refreshItems(ctx context.Context, ids []int) int {
+	jobs := make(chan int, len(ids))
+	for _, id := range ids {
+		jobs <- id
+	}
+	close(jobs)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		refreshed int
+	)
+	for range min(c.api().WorkerCount, len(ids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				key := fmt.Sprintf("item:%d", id)
+				_, err := c.itemFlight.Do(ctx, key, func(ctx context.Context) (*Item, error) {
+					return c.fetchItemUpstream(ctx, id)
+				})
+				if err != nil {
+					c.logger.Warn("failed to refresh updated item", "id", id, "error", err)
+					continue
+				}
+				mu.Lock()
+				refreshed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return refreshed
+}
@@ -7,3 +7,8 @@ type User struct {
 	About     string `json:"about"`
 	Submitted []int  `json:"submitted"`
 }
+
+func (user *User) Size() int {
+	const overhead = 80
+	return overhead + len(user.ID) + len(user.About) + 8*len(user.Submitted)
+}
@@ -0,0 +1,254 @@
+package hn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type watchOptions struct {
+	items int
+}
+
+type WatchOption func(*watchOptions)
+
+// WithItemStreams also streams the first n stories on the list, following the
+// list as it changes.
+func WithItemStreams(n int) WatchOption {
+	return func(o *watchOptions) {
+		o.items = n
+	}
+}
+
+func (c *Client) WatchStoryIDs(ctx context.Context, stream *Stream, storyType string, opts ...WatchOption) error {
+	var o watchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cacheKey := fmt.Sprintf("idlist:%s", storyType)
+	items := &itemStreams{client: c, stream: stream, active: make(map[int]context.CancelFunc)}
+	defer items.stop()
+
+	update := func(ids []int) {
+		c.idListCache.Set(cacheKey, ids)
+		if o.items > 0 {
+			items.sync(ctx, ids[:min(o.items, len(ids))])
+		}
+	}
+
+	return stream.Subscribe(ctx, fmt.Sprintf("/%sstories", storyType), func(ev Event) {
+		if ev.Type == "put" && ev.Path == "/" {
+			var ids []int
+			if err := json.Unmarshal(ev.Data, &ids); err != nil {
+				c.logger.Warn("failed to decode streamed story IDs", "type", storyType, "error", err)
+				return
+			}
+			update(ids)
+			return
+		}
+
+		current, found := c.idListCache.Peek(cacheKey)
+		if !found {
+			return
+		}
+		ids, err := patchIDList(slices.Clone(current), ev)
+		if err != nil {
+			c.logger.Warn("failed to apply streamed story ID change, refetching list", "type", storyType, "path", ev.Path, "error", err)
+			if ids, err = c.RefreshStoryIDs(ctx, storyType); err != nil {
+				c.logger.Warn("failed to refetch story IDs", "type", storyType, "error", err)
+				return
+			}
+		}
+		update(ids)
+	})
+}
+
+// itemStreams keeps one WatchItem stream open for each ID in the latest set.
+// It is only used from a single stream handler, so it needs no locking.
+type itemStreams struct {
+	client *Client
+	stream *Stream
+	active map[int]context.CancelFunc
+}
+
+func (s *itemStreams) sync(ctx context.Context, ids []int) {
+	want := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+		if _, ok := s.active[id]; ok {
+			continue
+		}
+		itemCtx, cancel := context.WithCancel(ctx)
+		s.active[id] = cancel
+		go s.client.WatchItem(itemCtx, s.stream, id)
+	}
+
+	for id, cancel := range s.active {
+		if !want[id] {
+			cancel()
+			delete(s.active, id)
+		}
+	}
+}
+
+func (s *itemStreams) stop() {
+	for _, cancel := range s.active {
+		cancel()
+	}
+}
+
+func (c *Client) WatchMaxItem(ctx context.Context, stream *Stream) error {
+	return stream.Subscribe(ctx, "/maxitem", func(ev Event) {
+		var id int
+		if err := json.Unmarshal(ev.Data, &id); err != nil || id == 0 {
+			return
+		}
+		if _, err := c.itemFlight.Do(ctx, fmt.Sprintf("item:%d", id), func(ctx context.Context) (*Item, error) {
+			return c.fetchItemUpstream(ctx, id)
+		}); err != nil {
+			c.logger.Warn("failed to fetch newest item", "id", id, "error", err)
+		}
+	})
+}
+
+func (c *Client) WatchItem(ctx context.Context, stream *Stream, id int) error {
+	cacheKey := fmt.Sprintf("item:%d", id)
+
+	return stream.Subscribe(ctx, fmt.Sprintf("/item/%d", id), func(ev Event) {
+		field := strings.Trim(ev.Path, "/")
+		switch {
+		case field == "" && ev.Type == "put":
+			if isNull(ev.Data) {
+				c.itemCache.Delete(cacheKey)
+				return
+			}
+			c.setStreamedItem(id, ev.Data)
+			return
+		case strings.Contains(field, "/") || (field != "" && ev.Type != "put"):
+			// Changes inside a field, such as one entry of kids, are rare
+			// enough that refetching the item beats applying them.
+			if _, err := c.itemFlight.Do(ctx, cacheKey, func(ctx context.Context) (*Item, error) {
+				return c.fetchItemUpstream(ctx, id)
+			}); err != nil {
+				c.logger.Warn("failed to refetch streamed item", "id", id, "path", ev.Path, "error", err)
+			}
+			return
+		}
+
+		current, found := c.itemCache.Peek(cacheKey)
+		if !found {
+			return
+		}
+		changes := map[string]json.RawMessage{field: ev.Data}
+		if field == "" {
+			changes = nil
+			if err := json.Unmarshal(ev.Data, &changes); err != nil {
+				c.logger.Warn("failed to apply streamed item change", "id", id, "path", ev.Path, "error", err)
+				return
+			}
+		}
+		data, err := mergeFields(current, changes)
+		if err != nil {
+			c.logger.Warn("failed to apply streamed item change", "id", id, "path", ev.Path, "error", err)
+			return
+		}
+		c.setStreamedItem(id, data)
+	})
+}
+
+// mergeFields returns item as JSON with the changed fields replaced, or
+// removed when they are null. The cached item is shared with readers, so
+// changes are never applied to it in place.
+func mergeFields(item *Item, changes map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range changes {
+		if isNull(value) {
+			delete(fields, name)
+		} else {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func (c *Client) setStreamedItem(id int, data []byte) {
+	cacheKey := fmt.Sprintf("item:%d", id)
+
+	var item Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		c.logger.Warn("failed to decode streamed item", "id", id, "error", err)
+		return
+	}
+	if item.Deleted || item.Dead {
+		c.itemCache.Delete(cacheKey)
+		return
+	}
+	c.itemCache.Set(cacheKey, &item)
+}
+
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// patchIDList applies a Firebase change to an array, which arrives either as a
+// put of a single index ("/3") or as a patch of index-keyed values at "/".
+// Removed indices arrive as null and are dropped from the result.
+func patchIDList(ids []int, ev Event) ([]int, error) {
+	changes := map[string]json.RawMessage{}
+	if ev.Path == "/" {
+		if err := json.Unmarshal(ev.Data, &changes); err != nil {
+			return nil, err
+		}
+	} else {
+		changes[strings.Trim(ev.Path, "/")] = ev.Data
+	}
+
+	type change struct {
+		idx int
+		raw json.RawMessage
+	}
+	sorted := make([]change, 0, len(changes))
+	for key, raw := range changes {
+		idx, err := strconv.Atoi(key)
+		if err != nil || idx < 0 {
+			return nil, fmt.Errorf("invalid index %q", key)
+		}
+		sorted = append(sorted, change{idx, raw})
+	}
+	// A change may append to the list but not leave a gap in it, so apply
+	// them in order.
+	slices.SortFunc(sorted, func(a, b change) int { return a.idx - b.idx })
+
+	for _, ch := range sorted {
+		if ch.idx > len(ids) {
+			return nil, fmt.Errorf("index %d past the end of a %d entry list", ch.idx, len(ids))
+		}
+
+		var id int
+		if !isNull(ch.raw) {
+			if err := json.Unmarshal(ch.raw, &id); err != nil {
+				return nil, err
+			}
+		}
+
+		if ch.idx == len(ids) {
+			ids = append(ids, id)
+		} else {
+			ids[ch.idx] = id
+		}
+	}
+
+	return slices.DeleteFunc(ids, func(id int) bool { return id == 0 }), nil
+}
@@ -0,0 +1,90 @@
+package hn
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestPatchIDList(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []int
+		ev      Event
+		want    []int
+		wantErr bool
+	}{
+		{
+			name: "put one index",
+			ids:  []int{1, 2, 3},
+			ev:   Event{Type: "put", Path: "/1", Data: json.RawMessage(`9`)},
+			want: []int{1, 9, 3},
+		},
+		{
+			name: "put at the end appends",
+			ids:  []int{1},
+			ev:   Event{Type: "put", Path: "/1", Data: json.RawMessage(`7`)},
+			want: []int{1, 7},
+		},
+		{
+			name: "patch appending several",
+			ids:  []int{1},
+			ev:   Event{Type: "patch", Path: "/", Data: json.RawMessage(`{"3": 4, "1": 2, "2": 3}`)},
+			want: []int{1, 2, 3, 4},
+		},
+		{
+			name:    "put leaving a gap",
+			ids:     []int{1},
+			ev:      Event{Type: "put", Path: "/2", Data: json.RawMessage(`7`)},
+			wantErr: true,
+		},
+		{
+			name:    "put far past the end",
+			ids:     []int{1},
+			ev:      Event{Type: "put", Path: "/123456789", Data: json.RawMessage(`7`)},
+			wantErr: true,
+		},
+		{
+			name: "put null removes",
+			ids:  []int{1, 2, 3},
+			ev:   Event{Type: "put", Path: "/0", Data: json.RawMessage(`null`)},
+			want: []int{2, 3},
+		},
+		{
+			name: "patch several indices",
+			ids:  []int{1, 2, 3, 4},
+			ev:   Event{Type: "patch", Path: "/", Data: json.RawMessage(`{"0": 8, "2": null, "3": null}`)},
+			want: []int{8, 2},
+		},
+		{
+			name:    "invalid index",
+			ids:     []int{1},
+			ev:      Event{Type: "patch", Path: "/", Data: json.RawMessage(`{"x": 1}`)},
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			ids:     []int{1},
+			ev:      Event{Type: "put", Path: "/0", Data: json.RawMessage(`"a"`)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patchIDList(tt.ids, tt.ev)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("patchIDList = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("patchIDList = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,49 +1,256 @@
 package view
 
 import (
+	"html"
 	"html/template"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
-var (
-	italicRegex = regexp.MustCompile(`(^|[^\*])\*([^\*]+)\*([^\*]|$)`)
-	urlRegex    = regexp.MustCompile(`(<(https?:\/\/[^\s<>"]+)>|https?:\/\/[^\s<>"]+)`)
+var urlRegex = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// maxLinkText is the length past which link text is shortened, as on HN.
+const maxLinkText = 60
+
+type blockKind int
+
+const (
+	paragraphBlock blockKind = iota
+	quoteBlock
+	codeBlock
 )
 
+type block struct {
+	kind   blockKind
+	inline []token
+	code   string
+}
+
+// FormatText renders the HTML that the Hacker News API returns for comments,
+// story text and profiles. Paragraphs are split on <p>, <pre><code> becomes a
+// code block, paragraphs starting with ">" become quotes, and bare URLs are
+// linked. Input is reduced to the allowlisted tags, links are limited to http
+// and https, and all text is escaped, so the result is safe to embed.
 func FormatText(text string) template.HTML {
 	if text == "" {
 		return ""
 	}
 
-	var result strings.Builder
-	paragraphs := strings.SplitSeq(text, "\n\n")
+	var b strings.Builder
+	inQuote := false
+	for _, blk := range parseBlocks(tokenize(text)) {
+		if blk.kind == quoteBlock && !inQuote {
+			b.WriteString("<blockquote>")
+		} else if blk.kind != quoteBlock && inQuote {
+			b.WriteString("</blockquote>")
+		}
+		inQuote = blk.kind == quoteBlock
+
+		switch blk.kind {
+		case codeBlock:
+			b.WriteString("<pre><code>" + html.EscapeString(blk.code) + "</code></pre>")
+		default:
+			b.WriteString("<p>")
+			writeInline(&b, blk.inline)
+			b.WriteString("</p>")
+		}
+	}
+	if inQuote {
+		b.WriteString("</blockquote>")
+	}
+	return template.HTML(b.String())
+}
+
+// parseBlocks groups tokens into paragraphs and code blocks. HN separates
+// paragraphs with a bare <p> and rarely closes them, so both start and end
+// tags end the current paragraph.
+func parseBlocks(tokens []token) []block {
+	var blocks []block
+	var para []token
+	var code strings.Builder
+	inPre := false
+
+	flush := func() {
+		if blk, ok := newParagraph(para); ok {
+			blocks = append(blocks, blk)
+		}
+		para = nil
+	}
 
-	for p := range paragraphs {
-		if strings.TrimSpace(p) == "" {
+	for _, tok := range tokens {
+		if inPre {
+			switch {
+			case tok.kind == textToken:
+				code.WriteString(tok.data)
+			case tok.kind == endToken && tok.data == "pre":
+				blocks = append(blocks, block{kind: codeBlock, code: strings.TrimRight(code.String(), "\n")})
+				code.Reset()
+				inPre = false
+			}
 			continue
 		}
 
-		if after, ok := strings.CutPrefix(p, "  "); ok {
-			codeContent := after
-			escapedCode := template.HTMLEscapeString(codeContent)
-			result.WriteString("<pre><code>" + escapedCode + "</code></pre>")
+		switch {
+		case tok.kind != textToken && tok.data == "p":
+			flush()
+		case tok.kind == startToken && tok.data == "pre":
+			flush()
+			inPre = true
+		case tok.kind == textToken:
+			// Plain text from older items separates paragraphs with blank lines.
+			parts := strings.Split(tok.data, "\n\n")
+			for i, part := range parts {
+				if i > 0 {
+					flush()
+				}
+				para = append(para, token{kind: textToken, data: part})
+			}
+		case tok.data != "pre":
+			para = append(para, tok)
+		}
+	}
+
+	if inPre {
+		blocks = append(blocks, block{kind: codeBlock, code: strings.TrimRight(code.String(), "\n")})
+	}
+	flush()
+	return blocks
+}
+
+// newParagraph trims a paragraph's leading whitespace and strips its quote
+// marker. It reports false if the paragraph has no text.
+func newParagraph(inline []token) (block, bool) {
+	blk := block{kind: paragraphBlock, inline: inline}
+
+	hasText := false
+	for _, tok := range inline {
+		if tok.kind == textToken && strings.TrimSpace(tok.data) != "" {
+			hasText = true
+			break
+		}
+	}
+	if !hasText {
+		return blk, false
+	}
+
+	for i, tok := range inline {
+		if tok.kind != textToken {
 			continue
 		}
+		text := strings.TrimLeft(tok.data, " \t\n")
+		if text == "" {
+			inline[i].data = ""
+			continue
+		}
+		if rest, ok := strings.CutPrefix(text, ">"); ok {
+			blk.kind = quoteBlock
+			text = strings.TrimLeft(rest, " ")
+		}
+		inline[i].data = text
+		break
+	}
+	return blk, true
+}
 
-		p = urlRegex.ReplaceAllStringFunc(p, func(match string) string {
-			url := strings.Trim(match, "<>")
-			return `<a href="` + url + `" rel="nofollow">` + template.HTMLEscapeString(url) + `</a>`
-		})
+func writeInline(b *strings.Builder, inline []token) {
+	var open []string
 
-		p = italicRegex.ReplaceAllString(p, "$1<i>$2</i>$3")
+	for i := 0; i < len(inline); i++ {
+		tok := inline[i]
+		switch {
+		case tok.kind == textToken:
+			if len(open) > 0 && open[len(open)-1] == "code" {
+				b.WriteString(html.EscapeString(tok.data))
+			} else {
+				writeAutolinked(b, tok.data)
+			}
 
-		p = strings.ReplaceAll(p, `\*`, `*`)
+		case tok.kind == startToken && tok.data == "a":
+			var text strings.Builder
+			for i+1 < len(inline) && !(inline[i+1].kind == endToken && inline[i+1].data == "a") {
+				i++
+				if inline[i].kind == textToken {
+					text.WriteString(inline[i].data)
+				}
+			}
+			i++
+			writeLink(b, tok.href, text.String())
 
-		p = strings.ReplaceAll(p, "\n", "<br>")
+		case tok.kind == startToken:
+			b.WriteString("<" + tok.data + ">")
+			open = append(open, tok.data)
 
-		result.WriteString("<p>" + p + "</p>")
+		case tok.kind == endToken:
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == tok.data {
+					for k := len(open) - 1; k >= j; k-- {
+						b.WriteString("</" + open[k] + ">")
+					}
+					open = open[:j]
+					break
+				}
+			}
+		}
 	}
 
-	return template.HTML(result.String())
+	for j := len(open) - 1; j >= 0; j-- {
+		b.WriteString("</" + open[j] + ">")
+	}
+}
+
+// writeLink writes an upstream link, falling back to its text when the URL
+// was rejected.
+func writeLink(b *strings.Builder, href, text string) {
+	if text == "" {
+		text = href
+	}
+	if href == "" {
+		b.WriteString(html.EscapeString(text))
+		return
+	}
+	b.WriteString(linkTag(href) + html.EscapeString(truncateLink(text)) + "</a>")
+}
+
+func writeAutolinked(b *strings.Builder, text string) {
+	for {
+		loc := urlRegex.FindStringIndex(text)
+		if loc == nil {
+			b.WriteString(html.EscapeString(text))
+			return
+		}
+
+		raw := trimURL(text[loc[0]:loc[1]])
+		b.WriteString(html.EscapeString(text[:loc[0]]))
+		if href := safeURL(raw); href != "" {
+			b.WriteString(linkTag(href) + html.EscapeString(truncateLink(raw)) + "</a>")
+		} else {
+			b.WriteString(html.EscapeString(raw))
+		}
+		text = text[loc[0]+len(raw):]
+	}
+}
+
+// trimURL drops trailing punctuation that more likely ends the sentence than
+// the URL, including closing brackets with no opening partner in the URL.
+func trimURL(u string) string {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'*", last) >= 0:
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+		case last == ']' && strings.Count(u, "[") < strings.Count(u, "]"):
+		default:
+			return u
+		}
+		u = u[:len(u)-1]
+	}
+	return u
+}
+
+func truncateLink(text string) string {
+	if utf8.RuneCountInString(text) <= maxLinkText || !urlRegex.MatchString(text) {
+		return text
+	}
+	return string([]rune(text)[:maxLinkText]) + "..."
 }
@@ -0,0 +1,289 @@
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"hackernews/internal/gemini"
+	"hackernews/internal/hn"
+)
+
+// WantsGemtext reports whether the request came through the Gemini server.
+// Plain HTTP clients can't ask for gemtext.
+func WantsGemtext(r *http.Request) bool {
+	return gemini.IsRequest(r.Context())
+}
+
+type gemWriter struct {
+	buf bytes.Buffer
+}
+
+var gemPages = map[string]func(*gemWriter, *TemplateData){
+	"index": (*gemWriter).stories,
+	"item":  (*gemWriter).item,
+	"user":  (*gemWriter).user,
+}
+
+// RenderGemtext writes a page, "index", "item" or "user", as text/gemini.
+// Links are relative so they resolve against the Gemini server.
+func RenderGemtext(w http.ResponseWriter, r *http.Request, page string, data *TemplateData) {
+	render, ok := gemPages[page]
+	if !ok {
+		http.Error(w, fmt.Sprintf("no gemtext rendering for %s", page), http.StatusInternalServerError)
+		return
+	}
+	data.Stale = hn.ServedStale(r.Context())
+
+	gw := &gemWriter{}
+	if data.Stale {
+		gw.line("Some of this data may be out of date.")
+		gw.line("")
+	}
+	render(gw, data)
+
+	w.Header().Set("Content-Type", "text/gemini; charset=utf-8")
+	gw.buf.WriteTo(w)
+}
+
+func (gw *gemWriter) stories(data *TemplateData) {
+	gw.line("# Hacker News: " + data.ActiveNav)
+	gw.line("")
+	gw.link("/", "top")
+	for _, nav := range []string{"new", "best", "ask", "show", "job", "polls"} {
+		gw.link("/"+nav, nav)
+	}
+	gw.line("")
+
+	for idx, story := range data.Stories {
+		if story == nil {
+			continue
+		}
+		rank := idx + (data.CurrentPage-1)*data.ItemsPerPage + 1
+		gw.story(strconv.Itoa(rank)+". ", story)
+	}
+
+	if data.MoreStories {
+		more := data.PagePath + "?page=" + strconv.Itoa(data.NextPage)
+		if data.FilterQuery != "" {
+			more += "&" + string(data.FilterQuery)
+		}
+		gw.link(more, "More")
+	}
+}
+
+func (gw *gemWriter) story(prefix string, story *hn.Item) {
+	title := story.Title
+	if host := story.Host(); host != "" {
+		title += " (" + host + ")"
+	}
+	if story.URL != "" {
+		gw.link(story.URL, prefix+title)
+	} else {
+		gw.link(itemPath(story.ID), prefix+title)
+	}
+	gw.line(fmt.Sprintf("%d points by %s %s", story.Score, story.By, story.TimeAgo()))
+	gw.link(itemPath(story.ID), fmt.Sprintf("%d comments", story.Descendants))
+	gw.line("")
+}
+
+func (gw *gemWriter) item(data *TemplateData) {
+	item := data.Item
+
+	for _, ancestor := range data.Ancestors {
+		label := ancestor.Title
+		if label == "" {
+			label = "comment by " + ancestor.By
+		}
+		gw.link(itemPath(ancestor.ID), "on: "+label)
+	}
+
+	depth := 0
+	if item.Title != "" {
+		gw.line("# " + oneLine(item.Title))
+		if item.URL != "" {
+			gw.link(item.URL, item.Host())
+		}
+		gw.line(fmt.Sprintf("%d points by %s %s | %d comments", item.Score, item.By, item.TimeAgo(), item.Descendants))
+		gw.link(userPath(item.By), item.By)
+		gw.line("")
+		gw.body("", item.Text)
+	} else {
+		gw.comment(0, item)
+		depth = 1
+	}
+
+	if len(item.Options) > 0 {
+		total := item.PollVotes()
+		for _, option := range item.Options {
+			share := 0
+			if total > 0 {
+				share = option.Score * 100 / total
+			}
+			var text []string
+			for _, blk := range parseBlocks(tokenize(option.Text)) {
+				text = append(text, inlineText(blk.inline))
+			}
+			gw.line(fmt.Sprintf("* %s: %d points (%d%%)", oneLine(strings.Join(text, " ")), option.Score, share))
+		}
+		gw.line("")
+	}
+
+	if len(item.Comments) > 0 {
+		gw.line("## Comments")
+		gw.line("")
+		gw.comments(item.Comments, depth)
+	}
+
+	if item.Truncated {
+		gw.line("Some comments could not be loaded.")
+	}
+	if data.MoreComments {
+		gw.link(fmt.Sprintf("%s&page=%d", itemPath(item.ID), data.NextPage), "More comments")
+	}
+}
+
+func (gw *gemWriter) comments(comments []*hn.Item, depth int) {
+	for _, comment := range comments {
+		if comment.Deleted {
+			continue
+		}
+		gw.comment(depth, comment)
+		gw.comments(comment.Comments, depth+1)
+		if comment.Truncated {
+			gw.link(itemPath(comment.ID), strings.Repeat("│ ", depth+1)+"load more")
+			gw.line("")
+		}
+	}
+}
+
+// comment writes a comment header and body. Gemtext has no indentation, so
+// depth is drawn with a bar before each line.
+func (gw *gemWriter) comment(depth int, comment *hn.Item) {
+	bars := strings.Repeat("│ ", min(depth, maxTextDepth))
+	gw.link(itemPath(comment.ID), bars+comment.By+" "+comment.TimeAgo())
+	gw.body(bars, comment.Text)
+}
+
+func (gw *gemWriter) user(data *TemplateData) {
+	user := data.User
+	gw.line("# " + user.ID)
+	gw.line("created: " + formatDate(user.Created))
+	gw.line("karma: " + strconv.Itoa(user.Karma))
+	gw.line("")
+	gw.body("", user.About)
+
+	base := userPath(user.ID)
+	gw.link(base+"&view=submissions", "submissions")
+	gw.link(base+"&view=comments", "comments")
+	gw.line("")
+
+	var count int
+	switch data.ActiveUserView {
+	case "submissions":
+		gw.line("## Submissions")
+		gw.line("")
+		for _, story := range data.Submissions {
+			gw.story("", story)
+		}
+		count = len(data.Submissions)
+	case "comments":
+		gw.line("## Comments")
+		gw.line("")
+		for _, comment := range data.Comments {
+			gw.comment(0, comment)
+			gw.link(itemPath(comment.Parent), "parent")
+			gw.line("")
+		}
+		count = len(data.Comments)
+	default:
+		return
+	}
+
+	if count == data.ItemsPerPage {
+		gw.link(fmt.Sprintf("%s&view=%s&page=%d", base, data.ActiveUserView, data.NextPage), "More")
+	}
+}
+
+// body writes upstream HTML as gemtext. Each paragraph is one line, prefixed
+// for nested comments, followed by link lines for the URLs it contains.
+func (gw *gemWriter) body(prefix, text string) {
+	for _, blk := range parseBlocks(tokenize(text)) {
+		switch blk.kind {
+		case codeBlock:
+			gw.line("```")
+			for line := range strings.SplitSeq(blk.code, "\n") {
+				if strings.HasPrefix(line, "```") {
+					line = " " + line
+				}
+				gw.line(line)
+			}
+			gw.line("```")
+		case quoteBlock:
+			gw.line(prefix + "> " + oneLine(inlineText(blk.inline)))
+		default:
+			gw.text(prefix + oneLine(inlineText(blk.inline)))
+		}
+
+		for _, href := range blockLinks(blk) {
+			gw.link(href, prefix+href)
+		}
+		gw.line("")
+	}
+}
+
+// blockLinks returns the URLs linked or written out in a paragraph.
+func blockLinks(blk block) []string {
+	var links []string
+	for _, tok := range blk.inline {
+		switch {
+		case tok.kind == startToken && tok.data == "a" && tok.href != "":
+			links = append(links, tok.href)
+		case tok.kind == textToken:
+			for _, match := range urlRegex.FindAllString(tok.data, -1) {
+				if href := safeURL(trimURL(match)); href != "" {
+					links = append(links, href)
+				}
+			}
+		}
+	}
+	return links
+}
+
+func itemPath(id int) string {
+	return "/item?id=" + strconv.Itoa(id)
+}
+
+func userPath(id string) string {
+	return "/user?id=" + url.QueryEscape(id)
+}
+
+func (gw *gemWriter) link(target, label string) {
+	gw.line("=> " + strings.ReplaceAll(target, " ", "%20") + " " + oneLine(label))
+}
+
+// oneLine collapses runs of whitespace, newlines included, to single spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// line writes one line of output. Every line passes through here, so this is
+// where control characters from upstream text are dropped.
+func (gw *gemWriter) line(s string) {
+	gw.buf.WriteString(stripControl(s) + "\n")
+}
+
+// text writes an upstream text line, shifted by a space if it would otherwise
+// read as gemtext markup.
+func (gw *gemWriter) text(s string) {
+	for _, prefix := range []string{"=>", "```", "#", "*", ">"} {
+		if strings.HasPrefix(s, prefix) {
+			s = " " + s
+			break
+		}
+	}
+	gw.line(s)
+}
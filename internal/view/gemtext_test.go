@@ -0,0 +1,42 @@
+package view
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode"
+
+	"hackernews/internal/hn"
+)
+
+func TestRenderGemtextStripsControlCharacters(t *testing.T) {
+	reply := &hn.Item{ID: 3, By: "eve\x1b[2J", Text: "deep\x1b[31mred\x07"}
+	data := &TemplateData{
+		Item: &hn.Item{
+			ID:       1,
+			Title:    "Title\x1b]0;owned\x07",
+			By:       "alice",
+			Text:     "<pre><code>code\x1b[0m</code></pre>",
+			Comments: []*hn.Item{{ID: 2, By: "bob", Text: "top\x08", Comments: []*hn.Item{reply}}},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	RenderGemtext(w, httptest.NewRequest("GET", "/item?id=1", nil), "item", data)
+
+	out := w.Body.String()
+	for _, want := range []string{"# Title]0;owned", "│ deep[31mred", "code[0m"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if i := strings.IndexFunc(out, func(r rune) bool { return r != '\n' && unicode.IsControl(r) }); i >= 0 {
+		t.Errorf("control character %q at %d in:\n%q", out[i], i, out)
+	}
+}
+
+func TestWantsGemtextOnlyFromGeminiServer(t *testing.T) {
+	if WantsGemtext(httptest.NewRequest("GET", "/item?id=1&format=gemini", nil)) {
+		t.Error("plain HTTP request with ?format=gemini selected gemtext")
+	}
+}
@@ -0,0 +1,224 @@
+package view
+
+import (
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// allowedTags is the markup Hacker News itself produces. Anything else is
+// dropped, keeping its text.
+var allowedTags = []string{"p", "a", "i", "pre", "code"}
+
+// droppedTags lose their content as well as their markup.
+var droppedTags = []string{"script", "style", "iframe", "object", "embed", "template", "noscript", "textarea", "title", "xmp", "noembed", "noframes", "plaintext"}
+
+type tokenKind int
+
+const (
+	textToken tokenKind = iota
+	startToken
+	endToken
+)
+
+// token is a piece of upstream HTML: decoded text, or an allowlisted tag. For
+// links, href holds the validated URL and is empty if the URL was unsafe.
+type token struct {
+	kind tokenKind
+	data string
+	href string
+}
+
+type tag struct {
+	name    string
+	closing bool
+	href    string
+}
+
+// tokenize splits untrusted HTML into text and allowlisted tags. Comments,
+// other tags and the content of droppedTags are discarded, and entities in
+// text are decoded.
+func tokenize(s string) []token {
+	var tokens []token
+	addText := func(text string) {
+		if text == "" {
+			return
+		}
+		text = html.UnescapeString(text)
+		if n := len(tokens); n > 0 && tokens[n-1].kind == textToken {
+			tokens[n-1].data += text
+			return
+		}
+		tokens = append(tokens, token{kind: textToken, data: text})
+	}
+
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			addText(s)
+			break
+		}
+		addText(s[:lt])
+		s = s[lt:]
+
+		if rest, ok := skipComment(s); ok {
+			s = rest
+			continue
+		}
+
+		t, rest, ok := parseTag(s)
+		if !ok {
+			addText("&lt;")
+			s = s[1:]
+			continue
+		}
+		s = rest
+
+		if !t.closing && slices.Contains(droppedTags, t.name) {
+			s = skipUntilClose(s, t.name)
+			continue
+		}
+		if !slices.Contains(allowedTags, t.name) {
+			continue
+		}
+
+		if t.closing {
+			tokens = append(tokens, token{kind: endToken, data: t.name})
+		} else {
+			tokens = append(tokens, token{kind: startToken, data: t.name, href: t.href})
+		}
+	}
+	return tokens
+}
+
+func linkTag(href string) string {
+	return `<a href="` + html.EscapeString(href) + `" rel="nofollow noreferrer">`
+}
+
+func skipComment(s string) (string, bool) {
+	switch {
+	case strings.HasPrefix(s, "<!--"):
+		if end := strings.Index(s[4:], "-->"); end >= 0 {
+			return s[4+end+3:], true
+		}
+		return "", true
+	case strings.HasPrefix(s, "<!"), strings.HasPrefix(s, "<?"):
+		if end := strings.IndexByte(s, '>'); end >= 0 {
+			return s[end+1:], true
+		}
+		return "", true
+	}
+	return s, false
+}
+
+func skipUntilClose(s, name string) string {
+	for i := 0; ; {
+		j := strings.Index(s[i:], "</")
+		if j < 0 {
+			return ""
+		}
+		i += j + 2
+		if len(s)-i >= len(name) && strings.EqualFold(s[i:i+len(name)], name) {
+			if end := strings.IndexByte(s[i:], '>'); end >= 0 {
+				return s[i+end+1:]
+			}
+			return ""
+		}
+	}
+}
+
+// parseTag reads a start or end tag at the beginning of s, which starts with
+// '<'. It reports false if s does not begin with a well-formed tag.
+func parseTag(s string) (tag, string, bool) {
+	var t tag
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		t.closing = true
+		i++
+	}
+
+	start := i
+	for i < len(s) && isNameByte(s[i]) {
+		i++
+	}
+	if i == start || !isLetter(s[start]) {
+		return tag{}, s, false
+	}
+	t.name = strings.ToLower(s[start:i])
+
+	for {
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			i++
+		}
+		if i >= len(s) {
+			return tag{}, s, false
+		}
+		if s[i] == '>' {
+			return t, s[i+1:], true
+		}
+
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		var value string
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i >= len(s) {
+				return tag{}, s, false
+			}
+			if q := s[i]; q == '"' || q == '\'' {
+				end := strings.IndexByte(s[i+1:], q)
+				if end < 0 {
+					return tag{}, s, false
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+
+		if name == "href" && t.href == "" {
+			t.href = safeURL(html.UnescapeString(value))
+		}
+	}
+}
+
+// safeURL returns the absolute http or https URL in s, or "" if s is anything
+// else.
+func safeURL(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNameByte(c byte) bool {
+	return isLetter(c) || '0' <= c && c <= '9' || c == '-'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,92 @@
+package view
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var xssPayloads = []struct {
+	name string
+	in   string
+	want string
+}{
+	{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<p>x</p>`},
+	{"mixed case javascript href", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<p>x</p>`},
+	{"leading space javascript href", `<a href=" javascript:alert(1)">x</a>`, `<p>x</p>`},
+	{"entity tab in scheme", `<a href="jav&#x09;ascript:alert(1)">x</a>`, `<p>x</p>`},
+	{"entity letter in scheme", `<a href="java&#115;cript:alert(1)">x</a>`, `<p>x</p>`},
+	{"slash separated attribute", `<a/href="javascript:alert(1)">x</a>`, `<p>x</p>`},
+	{"vbscript href", `<a href="vbscript:msgbox(1)">x</a>`, `<p>x</p>`},
+	{"data href", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, `<p>x</p>`},
+	{"protocol relative href", `<a href="//evil.com">x</a>`, `<p>x</p>`},
+	{"img onerror", `<img src=x onerror=alert(1)>`, ``},
+	{"event attribute on p", `<p onclick="alert(1)">hi`, `<p>hi</p>`},
+	{"event attribute on link", `<a href="https://ok.com" onmouseover="alert(1)">x</a>`, `<p><a href="https://ok.com" rel="nofollow noreferrer">x</a></p>`},
+	{"split script tag", `<scr<script>ipt>alert(1)</script>`, `<p>ipt&gt;alert(1)</p>`},
+	{"doubled script tag", `<<script>script>alert(1)<</script>/script>`, `<p>&lt;/script&gt;</p>`},
+	{"script", `<script>alert(1)</script>after`, `<p>after</p>`},
+	{"mixed case script", `<ScRiPt>alert(1)</sCrIpT>`, ``},
+	{"script with lowercase growing text", "<script>ȺȺȺȺȺȺȺȺȺȺ</script>after", `<p>after</p>`},
+	{"script with invalid utf-8", "<sCript>\xf8\xf8\xf8\xf8\xf8</sCript", ``},
+	{"svg script", `<svg><script>alert(1)</script></svg>`, ``},
+	{"unterminated style", `<style>body{display:none}`, ``},
+	{"unterminated style tag", `<style`, `<p>&lt;style</p>`},
+	{"textarea content", `<textarea><a href="https://x">x</a></textarea>`, ``},
+	{"iframe", `<iframe src="https://evil.com"></iframe>`, ``},
+	{"commented script", `<!--<script>alert(1)</script>-->ok`, `<p>ok</p>`},
+	{"entity quotes in href", `<a href="https://ok.com/&quot;onmouseover=&quot;alert(1)">x</a>`, `<p><a href="https://ok.com/%22onmouseover=%22alert%281%29" rel="nofollow noreferrer">x</a></p>`},
+	{"quote breakout in href", `<a href='https://ok.com/"><script>alert(1)</script>'>x</a>`, `<p><a href="https://ok.com/%22%3E%3Cscript%3Ealert%281%29%3C/script%3E" rel="nofollow noreferrer">x</a></p>`},
+	{"escaped markup in link text", `<a href="https://ok.com">&lt;script&gt;</a>`, `<p><a href="https://ok.com" rel="nofollow noreferrer">&lt;script&gt;</a></p>`},
+	{"nested links", `<a href="https://ok.com">x<a href="https://b.com">y</a>`, `<p><a href="https://ok.com" rel="nofollow noreferrer">xy</a></p>`},
+	{"unquoted href", `<a href=https://ok.com>x</a>`, `<p><a href="https://ok.com" rel="nofollow noreferrer">x</a></p>`},
+	{"unclosed tags", `<i>unclosed <code>x`, `<p><i>unclosed <code>x</code></i></p>`},
+	{"stray end tags", `</p></i>stray`, `<p>stray</p>`},
+	{"bare angle brackets", `a < b && c > d`, `<p>a &lt; b &amp;&amp; c &gt; d</p>`},
+	{"escaped code", `<pre><code>&lt;b&gt;</code></pre>`, `<pre><code>&lt;b&gt;</code></pre>`},
+	{"autolink quote breakout", `see https://ok.com/"onmouseover="alert(1) now`, `<p>see <a href="https://ok.com/" rel="nofollow noreferrer">https://ok.com/</a>&#34;onmouseover=&#34;alert(1) now</p>`},
+	{"autolink entity quote", `https://ok.com/&quot;&gt;&lt;script&gt;alert(1)&lt;/script&gt;`, `<p><a href="https://ok.com/" rel="nofollow noreferrer">https://ok.com/</a>&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;</p>`},
+	{"autolink javascript", `javascript:alert(1)//https://ok.com`, `<p>javascript:alert(1)//<a href="https://ok.com" rel="nofollow noreferrer">https://ok.com</a></p>`},
+	{"truncated long link", `https://ok.com/` + strings.Repeat("a", 50) + `&lt;script&gt;`, `<p><a href="https://ok.com/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" rel="nofollow noreferrer">https://ok.com/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa...</a>&lt;script&gt;</p>`},
+	{"link text with markup", `<a href="https://ok.com"><i>&lt;img src=x onerror=alert(1)&gt;</i></a>`, `<p><a href="https://ok.com" rel="nofollow noreferrer">&lt;img src=x onerror=alert(1)&gt;</a></p>`},
+	{"quoted script", `<p>&gt; <script>alert(1)</script>quoted`, `<blockquote><p>quoted</p></blockquote>`},
+	{"script inside pre", `<pre><code><script>alert(1)</script>&lt;/pre&gt;</code></pre>`, `<pre><code>&lt;/pre&gt;</code></pre>`},
+	{"blockquote tag", `<blockquote onclick="alert(1)">x</blockquote>`, `<p>x</p>`},
+}
+
+func TestFormatTextXSSPayloads(t *testing.T) {
+	for _, tt := range xssPayloads {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(FormatText(tt.in)); got != tt.want {
+				t.Errorf("FormatText(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// allowedMarkup matches every tag FormatText may emit.
+var allowedMarkup = regexp.MustCompile(`^(?:</?(?:p|i|pre|code|a|blockquote)>|<a href="https?://[^"<>]*" rel="nofollow noreferrer">)`)
+
+func FuzzFormatText(f *testing.F) {
+	for _, tt := range xssPayloads {
+		f.Add(tt.in)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		out := string(FormatText(in))
+		for i := 0; i < len(out); {
+			switch out[i] {
+			case '<':
+				loc := allowedMarkup.FindStringIndex(out[i:])
+				if loc == nil {
+					t.Fatalf("FormatText(%q) = %q: disallowed markup at %d", in, out, i)
+				}
+				i += loc[1]
+			case '>', '"':
+				t.Fatalf("FormatText(%q) = %q: unescaped %q at %d", in, out, out[i], i)
+			default:
+				i++
+			}
+		}
+	})
+}
@@ -16,14 +16,24 @@ import (
 type TemplateData struct {
 	Stories        []*hn.Item
 	Item           *hn.Item
+	Ancestors      []*hn.Item
+	MoreComments   bool
 	User           *hn.User
 	Submissions    []*hn.Item
 	Comments       []*hn.Item
 	ActiveNav      string
+	PagePath       string
+	FeedPath       string
+	FilterQuery    template.URL
+	MoreStories    bool
 	ActiveUserView string
 	CurrentPage    int
 	NextPage       int
 	ItemsPerPage   int
+	StatusText     string
+	ErrorMessage   string
+	Stale          bool
+	SiteURL        string
 }
 
 func formatDate(t int64) string {
@@ -42,8 +52,11 @@ var functions = template.FuncMap{
 	"add": func(a, b int) int {
 		return a + b
 	},
-	"safeHTML": func(s string) template.HTML {
-		return template.HTML(s)
+	"percent": func(part, total int) int {
+		if total <= 0 {
+			return 0
+		}
+		return part * 100 / total
 	},
 	"rank": func(idx, page, itemsPerPage int) int {
 		return idx + ((page - 1) * itemsPerPage) + 1
@@ -87,11 +100,18 @@ func NewTemplateCache(dir fs.FS) (map[string]*template.Template, error) {
 }
 
 func Render(w http.ResponseWriter, r *http.Request, t *template.Template, data *TemplateData) {
+	RenderStatus(w, r, t, http.StatusOK, data)
+}
+
+func RenderStatus(w http.ResponseWriter, r *http.Request, t *template.Template, status int, data *TemplateData) {
+	data.Stale = hn.ServedStale(r.Context())
+
 	buf := new(bytes.Buffer)
 	err := t.ExecuteTemplate(buf, "base", data)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("error executing template: %v", err), http.StatusInternalServerError)
 		return
 	}
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
@@ -0,0 +1,394 @@
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"hackernews/internal/hn"
+)
+
+const (
+	defaultTextWidth = 80
+	minTextWidth     = 40
+	maxTextWidth     = 200
+	maxTextDepth     = 10
+)
+
+const (
+	ansiHeader = "1;38;5;208"
+	ansiTitle  = "1"
+	ansiMeta   = "2"
+	ansiURL    = "36"
+	ansiRank   = "38;5;208"
+)
+
+// WantsText reports whether the client asked for the plain text rendering,
+// either with ?format=text, an Accept header preferring text/plain, or by
+// being curl or Wget without a preference.
+func WantsText(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "text":
+		return true
+	case "":
+	default:
+		return false
+	}
+
+	accept := r.Header.Get("Accept")
+	if plain := strings.Index(accept, "text/plain"); plain >= 0 {
+		html := strings.Index(accept, "text/html")
+		return html < 0 || plain < html
+	}
+	if accept == "" || accept == "*/*" {
+		ua := r.Header.Get("User-Agent")
+		return strings.HasPrefix(ua, "curl/") || strings.HasPrefix(ua, "Wget/")
+	}
+	return false
+}
+
+// SiteURL returns the scheme and host the request was made to, so absolute
+// links work behind a TLS-terminating proxy. The Host and X-Forwarded-Proto
+// headers come from the client, so this is only a fallback for when no public
+// URL is configured.
+func SiteURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+type textWriter struct {
+	buf   bytes.Buffer
+	width int
+	color bool
+	base  string
+}
+
+var textPages = map[string]func(*textWriter, *TemplateData){
+	"index": (*textWriter).stories,
+	"item":  (*textWriter).item,
+	"user":  (*textWriter).user,
+}
+
+// RenderText writes the plain text rendering of a page: "index", "item" or
+// "user". ?width= sets the wrap width and ?color=1 or 0 forces ANSI colors on
+// or off; by default only curl gets colors.
+func RenderText(w http.ResponseWriter, r *http.Request, page string, data *TemplateData) {
+	render, ok := textPages[page]
+	if !ok {
+		http.Error(w, fmt.Sprintf("no text rendering for %s", page), http.StatusInternalServerError)
+		return
+	}
+	data.Stale = hn.ServedStale(r.Context())
+
+	q := r.URL.Query()
+	tw := &textWriter{
+		width: defaultTextWidth,
+		color: strings.HasPrefix(r.Header.Get("User-Agent"), "curl/"),
+		base:  data.SiteURL,
+	}
+	if tw.base == "" {
+		tw.base = SiteURL(r)
+	}
+	if n, err := strconv.Atoi(q.Get("width")); err == nil {
+		tw.width = max(minTextWidth, min(n, maxTextWidth))
+	}
+	if c, err := strconv.ParseBool(q.Get("color")); err == nil {
+		tw.color = c
+	}
+
+	if data.Stale {
+		tw.line("", tw.style(ansiMeta, "Some of this data may be out of date."))
+		tw.blank()
+	}
+	render(tw, data)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	tw.buf.WriteTo(w)
+}
+
+func (tw *textWriter) stories(data *TemplateData) {
+	tw.line("", tw.style(ansiHeader, "Hacker News")+" "+tw.style(ansiMeta, data.ActiveNav))
+	tw.blank()
+
+	for idx, story := range data.Stories {
+		if story == nil {
+			continue
+		}
+		rank := fmt.Sprintf("%3d. ", idx+(data.CurrentPage-1)*data.ItemsPerPage+1)
+		tw.story(tw.style(ansiRank, rank), strings.Repeat(" ", len(rank)), story)
+	}
+
+	if data.MoreStories {
+		q := url.Values{"page": {strconv.Itoa(data.NextPage)}, "format": {"text"}}
+		more := data.PagePath + "?" + q.Encode()
+		if data.FilterQuery != "" {
+			more += "&" + string(data.FilterQuery)
+		}
+		tw.line("", "More: "+tw.style(ansiURL, tw.base+more))
+	}
+}
+
+func (tw *textWriter) story(first, indent string, story *hn.Item) {
+	title := story.Title
+	if host := story.Host(); host != "" {
+		title += " (" + host + ")"
+	}
+	for i, line := range wrapText(title, tw.width-utf8.RuneCountInString(indent)) {
+		prefix := indent
+		if i == 0 {
+			prefix = first
+		}
+		tw.line(prefix, tw.style(ansiTitle, line))
+	}
+	if story.URL != "" {
+		tw.line(indent, tw.style(ansiURL, story.URL))
+	}
+	meta := fmt.Sprintf("%d points by %s %s | %d comments", story.Score, story.By, story.TimeAgo(), story.Descendants)
+	tw.line(indent, tw.style(ansiMeta, meta)+" "+tw.style(ansiURL, tw.itemURL(story.ID)))
+	tw.blank()
+}
+
+func (tw *textWriter) item(data *TemplateData) {
+	item := data.Item
+
+	if len(data.Ancestors) > 0 {
+		var path []string
+		for _, ancestor := range data.Ancestors {
+			if ancestor.Title != "" {
+				path = append(path, ancestor.Title)
+			} else {
+				path = append(path, ancestor.By)
+			}
+		}
+		tw.line("", tw.style(ansiMeta, "on: "+strings.Join(path, " > ")))
+		tw.blank()
+	}
+
+	depth := 0
+	if item.Title != "" {
+		tw.story("", "", item)
+		tw.body("", item.Text)
+	} else {
+		tw.comment("", item)
+		depth = 1
+	}
+
+	if len(item.Options) > 0 {
+		total := item.PollVotes()
+		for _, option := range item.Options {
+			share := 0
+			if total > 0 {
+				share = option.Score * 100 / total
+			}
+			var text []string
+			for _, blk := range parseBlocks(tokenize(option.Text)) {
+				text = append(text, inlineText(blk.inline))
+			}
+			for _, line := range wrapText(strings.Join(text, " "), tw.width-2) {
+				tw.line("  ", line)
+			}
+			bar := strings.Repeat("█", share/5)
+			tw.line("  ", tw.style(ansiRank, bar)+tw.style(ansiMeta, fmt.Sprintf(" %d points (%d%%)", option.Score, share)))
+			tw.blank()
+		}
+	}
+
+	tw.comments(item.Comments, depth)
+
+	if item.Truncated {
+		tw.line("", tw.style(ansiMeta, "Some comments could not be loaded."))
+	}
+	if data.MoreComments {
+		tw.line("", "More: "+tw.style(ansiURL, fmt.Sprintf("%s?page=%d&format=text", tw.itemURL(item.ID), data.NextPage)))
+	}
+}
+
+func (tw *textWriter) comments(comments []*hn.Item, depth int) {
+	indent := strings.Repeat("  ", min(depth, maxTextDepth))
+	for _, comment := range comments {
+		if comment.Deleted {
+			continue
+		}
+		tw.comment(indent, comment)
+		tw.comments(comment.Comments, depth+1)
+		if comment.Truncated {
+			tw.line(indent+"  ", tw.style(ansiMeta, "load more: ")+tw.style(ansiURL, tw.itemURL(comment.ID)))
+			tw.blank()
+		}
+	}
+}
+
+func (tw *textWriter) comment(indent string, comment *hn.Item) {
+	tw.line(indent, tw.style(ansiTitle, comment.By)+" "+tw.style(ansiMeta, comment.TimeAgo())+" "+tw.style(ansiURL, tw.itemURL(comment.ID)))
+	tw.body(indent, comment.Text)
+}
+
+func (tw *textWriter) user(data *TemplateData) {
+	user := data.User
+	tw.line("", tw.style(ansiMeta, "user:    ")+tw.style(ansiTitle, user.ID))
+	tw.line("", tw.style(ansiMeta, "created: ")+formatDate(user.Created))
+	tw.line("", tw.style(ansiMeta, "karma:   ")+strconv.Itoa(user.Karma))
+	if user.About != "" {
+		tw.line("", tw.style(ansiMeta, "about:"))
+		tw.body("  ", user.About)
+	} else {
+		tw.blank()
+	}
+
+	var count int
+	switch data.ActiveUserView {
+	case "submissions":
+		for _, story := range data.Submissions {
+			tw.story("", "", story)
+		}
+		count = len(data.Submissions)
+		if count == 0 {
+			tw.line("", "This user has no submissions.")
+		}
+	case "comments":
+		for _, comment := range data.Comments {
+			tw.line("", tw.style(ansiMeta, comment.TimeAgo()+" on ")+tw.style(ansiURL, tw.itemURL(comment.Parent)))
+			tw.body("  ", comment.Text)
+		}
+		count = len(data.Comments)
+		if count == 0 {
+			tw.line("", "This user has no comments.")
+		}
+	default:
+		base := tw.base + "/user?id=" + url.QueryEscape(user.ID)
+		tw.line("", "submissions: "+tw.style(ansiURL, base+"&view=submissions&format=text"))
+		tw.line("", "comments:    "+tw.style(ansiURL, base+"&view=comments&format=text"))
+		return
+	}
+
+	if count == data.ItemsPerPage {
+		q := url.Values{"id": {user.ID}, "view": {data.ActiveUserView}, "page": {strconv.Itoa(data.NextPage)}, "format": {"text"}}
+		tw.line("", "More: "+tw.style(ansiURL, tw.base+"/user?"+q.Encode()))
+	}
+}
+
+// body writes upstream HTML as wrapped paragraphs, quotes and code blocks,
+// each followed by a blank line.
+func (tw *textWriter) body(indent, text string) {
+	for _, blk := range parseBlocks(tokenize(text)) {
+		switch blk.kind {
+		case codeBlock:
+			for line := range strings.SplitSeq(blk.code, "\n") {
+				tw.line(indent, stripControl(line))
+			}
+		case quoteBlock:
+			for _, line := range wrapText(inlineText(blk.inline), tw.width-utf8.RuneCountInString(indent)-2) {
+				tw.line(indent, tw.style(ansiMeta, "> "+line))
+			}
+		default:
+			for _, line := range wrapText(inlineText(blk.inline), tw.width-utf8.RuneCountInString(indent)) {
+				tw.line(indent, line)
+			}
+		}
+		tw.blank()
+	}
+}
+
+// inlineText flattens a paragraph to plain text, spelling out link targets
+// and marking italics and code the way HN comments are typed.
+func inlineText(inline []token) string {
+	var b strings.Builder
+	for i := 0; i < len(inline); i++ {
+		tok := inline[i]
+		switch {
+		case tok.kind == textToken:
+			b.WriteString(tok.data)
+		case tok.kind == startToken && tok.data == "a":
+			var text strings.Builder
+			for i+1 < len(inline) && !(inline[i+1].kind == endToken && inline[i+1].data == "a") {
+				i++
+				if inline[i].kind == textToken {
+					text.WriteString(inline[i].data)
+				}
+			}
+			i++
+			switch {
+			case tok.href == "":
+				b.WriteString(text.String())
+			case text.Len() == 0 || urlRegex.MatchString(text.String()):
+				b.WriteString(tok.href)
+			default:
+				b.WriteString(text.String() + " (" + tok.href + ")")
+			}
+		case tok.data == "i":
+			b.WriteString("*")
+		case tok.data == "code":
+			b.WriteString("`")
+		}
+	}
+	return b.String()
+}
+
+// wrap breaks text into lines of at most width runes, splitting only between
+// words so long URLs stay intact.
+func wrapText(text string, width int) []string {
+	width = max(width, minTextWidth/2)
+
+	var lines []string
+	var line strings.Builder
+	lineLen := 0
+	for _, word := range strings.Fields(text) {
+		word = stripControl(word)
+		n := utf8.RuneCountInString(word)
+		if lineLen > 0 && lineLen+1+n > width {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			line.WriteByte(' ')
+			lineLen++
+		}
+		line.WriteString(word)
+		lineLen += n
+	}
+	if lineLen > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+func (tw *textWriter) itemURL(id int) string {
+	return tw.base + "/item?id=" + strconv.Itoa(id)
+}
+
+func (tw *textWriter) style(code, s string) string {
+	s = stripControl(s)
+	if !tw.color || s == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// stripControl removes C0 and C1 control characters, ESC included, from
+// upstream text so it cannot send its own escape sequences to the terminal.
+// Tabs are kept for code blocks.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func (tw *textWriter) line(indent, s string) {
+	tw.buf.WriteString(strings.TrimRight(indent+s, " ") + "\n")
+}
+
+func (tw *textWriter) blank() {
+	tw.buf.WriteByte('\n')
+}